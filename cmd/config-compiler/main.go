@@ -0,0 +1,623 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/davidmdm/yaml"
+)
+
+const usage = `config-compiler compiles CircleCI 2.1 configuration into 2.0 configuration.
+
+Usage:
+  config-compiler <command> [flags] [config]
+
+Commands:
+  compile   compile the config and write the result
+  validate  compile the config and report any errors
+  params    list the pipeline parameters declared by the config
+  pack      assemble a config split across a directory and write the result
+  graph     compile the config and write its workflows as a DOT or Mermaid graph
+
+The config is read from the given path, or from stdin when the path is omitted or "-". A directory
+is packed into a single config, with each file becoming a key named after it and @ files, such as
+@config.yml, merged into their directory.
+Errors are written to stderr, or as JSON diagnostics to stdout with --format json.
+Run "config-compiler <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var cmd func(args []string, stdin io.Reader, stdout, stderr io.Writer) error
+
+	switch args[0] {
+	case "compile":
+		cmd = compileCmd
+	case "validate":
+		cmd = validateCmd
+	case "params":
+		cmd = paramsCmd
+	case "pack":
+		cmd = packCmd
+	case "graph":
+		cmd = graphCmd
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command: %s\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err := cmd(args[1:], stdin, stdout, stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		if errors.Is(err, errFlags) {
+			return 2
+		}
+		var jsonErr jsonError
+		if errors.As(err, &jsonErr) {
+			encoder := json.NewEncoder(stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(config.Diagnose(jsonErr.error)); err != nil {
+				fmt.Fprintln(stderr, err)
+			}
+			return 1
+		}
+		fmt.Fprintln(stderr, err)
+		if errors.As(err, new(usageError)) {
+			return 2
+		}
+		return 1
+	}
+
+	return 0
+}
+
+// errFlags is returned when flag parsing fails. The flag package has already reported the error.
+var errFlags = errors.New("invalid flags")
+
+// jsonError marks compile errors that should be reported as JSON diagnostics.
+type jsonError struct{ error }
+
+func (err jsonError) Unwrap() error {
+	return err.error
+}
+
+type usageError string
+
+func (err usageError) Error() string {
+	return string(err)
+}
+
+type compileFlags struct {
+	Params        paramFlags
+	Pipeline      pipelineFlags
+	ParamsFile    string
+	Output        string
+	Format        string
+	OrbDirs       stringsFlag
+	OrbRegistries stringsFlag
+	OrbCache      string
+	OrbCacheTTL   time.Duration
+	Offline       bool
+	OrbLock       string
+	UpdateOrbLock bool
+	IncludeRoot   string
+	PathMapping   string
+	ChangedFiles  stringsFlag
+	GitBase       string
+	Graph         string
+	Strict        bool
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
+
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errFlags
+	}
+	return nil
+}
+
+func (opts *compileFlags) register(flags *flag.FlagSet, withOutput bool) {
+	flags.Var(&opts.Params, "param", "pipeline parameter of the form key=value (repeatable)")
+	flags.StringVar(&opts.ParamsFile, "params-file", "", "path to a YAML or JSON file of pipeline parameters")
+	flags.Var(&opts.Pipeline, "pipeline", "pipeline value such as git.branch=main or number=42 (repeatable)")
+	flags.StringVar(&opts.Format, "format", "text", "format of reported errors: text or json")
+	flags.Var(&opts.OrbDirs, "orb-dir", "directory of orbs laid out as <namespace>/<name>@<version>.yml (repeatable)")
+	flags.Var(&opts.OrbRegistries, "orb-registry", "base url of an HTTP orb registry (repeatable)")
+	defaultCache, _ := config.DefaultOrbCacheDir()
+	flags.StringVar(&opts.OrbCache, "orb-cache", defaultCache, "directory to cache fetched orbs in, empty to disable caching")
+	flags.DurationVar(&opts.OrbCacheTTL, "orb-cache-ttl", time.Hour, "how long orbs that are not pinned to a version stay cached")
+	flags.BoolVar(&opts.Offline, "offline", false, "only use cached orbs and orb directories, never fetching orbs")
+	flags.StringVar(&opts.OrbLock, "orb-lock", "", "path to an orbs.lock file to verify orbs against, which must be pinned to exact versions")
+	flags.StringVar(&opts.PathMapping, "path-mapping", "", "path to a path-filtering mapping whose parameters are set for the changed files")
+	flags.Var(&opts.ChangedFiles, "changed", "changed file to apply the --path-mapping to (repeatable)")
+	flags.StringVar(&opts.GitBase, "git-base", "", "revision to diff HEAD against for the files changed, as the path-filtering orb does")
+	flags.StringVar(&opts.IncludeRoot, "include-root", "", "directory that <<include(path)>> directives are resolved against (default the config's directory)")
+	flags.BoolVar(&opts.Strict, "strict", false, "report unknown keys in jobs, commands, executors and workflows")
+	if withOutput {
+		flags.BoolVar(&opts.UpdateOrbLock, "update-orb-lock", false, "rewrite the orbs.lock file instead of verifying against it")
+		flags.StringVar(&opts.Output, "o", "", "path to write the compiled config to (default stdout)")
+		flags.StringVar(&opts.Output, "output", "", "path to write the compiled config to (default stdout)")
+	}
+}
+
+func (opts compileFlags) pipelineParams() (map[string]any, error) {
+	parameters, err := opts.pathFilterParams()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ParamsFile != "" {
+		data, err := os.ReadFile(opts.ParamsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read params file: %w", err)
+		}
+		var fileParams map[string]any
+		if err := yaml.Unmarshal(data, &fileParams); err != nil {
+			return nil, fmt.Errorf("failed to parse params file %s: %w", opts.ParamsFile, err)
+		}
+		for key, value := range fileParams {
+			parameters[key] = value
+		}
+	}
+
+	for _, kv := range opts.Params {
+		parameters[kv.Key] = kv.Value
+	}
+
+	values := map[string]any{}
+
+	for _, kv := range opts.Pipeline {
+		path := strings.Split(kv.Key, ".")
+		if path[0] == "parameters" {
+			return nil, usageError("pipeline parameters are set with --param, not --pipeline")
+		}
+
+		current := values
+		for _, segment := range path[:len(path)-1] {
+			next, ok := current[segment].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				current[segment] = next
+			}
+			current = next
+		}
+		current[path[len(path)-1]] = kv.Value
+	}
+
+	values["parameters"] = parameters
+
+	return values, nil
+}
+
+// pathFilterParams returns the pipeline parameters that the --path-mapping sets for the changed files, given by
+// --changed or by the files changed since the merge base of --git-base and HEAD.
+func (opts compileFlags) pathFilterParams() (map[string]any, error) {
+	if opts.PathMapping == "" {
+		if len(opts.ChangedFiles) > 0 || opts.GitBase != "" {
+			return nil, usageError("--changed and --git-base require --path-mapping")
+		}
+		return map[string]any{}, nil
+	}
+
+	data, err := os.ReadFile(opts.PathMapping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read path mapping: %w", err)
+	}
+
+	mappings, err := config.ParsePathMappings(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", opts.PathMapping, err)
+	}
+
+	changed := []string(opts.ChangedFiles)
+	if opts.GitBase != "" {
+		files, err := gitChangedFiles(opts.GitBase)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, files...)
+	}
+
+	return config.FilterPaths(mappings, changed), nil
+}
+
+// gitChangedFiles lists the files changed between the merge base of base and HEAD, and HEAD.
+func gitChangedFiles(base string) ([]string, error) {
+	output, err := exec.Command("git", "diff", "--name-only", base+"...HEAD").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("failed to diff against %s: %s", base, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to diff against %s: %w", base, err)
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// orbResolver tries the orb directories and registries in the order they were given, before
+// falling back to circleci.com. Orbs fetched over the network are cached unless caching is disabled.
+func (opts compileFlags) orbResolver() config.OrbResolver {
+	var chain config.ChainResolver
+	for _, dir := range opts.OrbDirs {
+		chain = append(chain, config.DirResolver(dir))
+	}
+
+	var remote config.ChainResolver
+	for _, registry := range opts.OrbRegistries {
+		remote = append(remote, config.HTTPResolver{BaseURL: registry})
+	}
+	remote = append(remote, config.GraphQLResolver{})
+
+	switch {
+	case opts.OrbCache != "":
+		return append(chain, config.CacheResolver{
+			Resolver: remote,
+			Dir:      opts.OrbCache,
+			TTL:      opts.OrbCacheTTL,
+			Offline:  opts.Offline,
+		})
+	case opts.Offline:
+		return append(chain, config.OrbResolverFunc(func(ref string) (string, error) {
+			return "", fmt.Errorf("%w: %s", config.ErrOrbNotCached, ref)
+		}))
+	default:
+		return append(chain, remote...)
+	}
+}
+
+func compileCmd(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var opts compileFlags
+
+	flags := newFlagSet("compile", stderr)
+	opts.register(flags, true)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	lock, err := opts.orbLock()
+	if err != nil {
+		return err
+	}
+
+	compiled, err := compileSource(flags, opts, lock, stdin)
+	if err != nil {
+		return err
+	}
+
+	if lock != nil {
+		data, err := lock.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(opts.OrbLock, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write orb lockfile: %w", err)
+		}
+	}
+
+	if opts.Output == "" || opts.Output == "-" {
+		_, err := stdout.Write(compiled)
+		return err
+	}
+
+	return os.WriteFile(opts.Output, compiled, 0o644)
+}
+
+func validateCmd(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var opts compileFlags
+
+	flags := newFlagSet("validate", stderr)
+	opts.register(flags, false)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	lock, err := opts.orbLock()
+	if err != nil {
+		return err
+	}
+
+	if _, err := compileSource(flags, opts, lock, stdin); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "config %s is valid\n", sourceName(flags))
+	return nil
+}
+
+func paramsCmd(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("params", stderr)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	source, err := readSource(flags, stdin)
+	if err != nil {
+		return err
+	}
+
+	parameters, err := config.PipelineParameters(source)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, name := range names {
+		param := parameters[name]
+		fmt.Fprintf(w, "%s\t%s\t%v\t%s\n", name, param.Type, param.Default, param.Description)
+	}
+
+	return w.Flush()
+}
+
+func packCmd(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("pack", stderr)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return usageError("expected a directory to pack")
+	}
+
+	source, err := config.Pack(os.DirFS(flags.Arg(0)))
+	if err != nil {
+		return err
+	}
+
+	_, err = stdout.Write(source)
+	return err
+}
+
+func graphCmd(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var opts compileFlags
+
+	flags := newFlagSet("graph", stderr)
+	opts.register(flags, false)
+	flags.StringVar(&opts.Graph, "graph", "dot", "format of the graph: dot or mermaid")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if format := config.GraphFormat(opts.Graph); format != config.GraphDOT && format != config.GraphMermaid {
+		return usageError(fmt.Sprintf("unknown graph format: %s", opts.Graph))
+	}
+
+	lock, err := opts.orbLock()
+	if err != nil {
+		return err
+	}
+
+	graph, err := compileSource(flags, opts, lock, stdin)
+	if err != nil {
+		return err
+	}
+
+	_, err = stdout.Write(graph)
+	return err
+}
+
+func compileSource(flags *flag.FlagSet, opts compileFlags, lock *config.OrbLock, stdin io.Reader) ([]byte, error) {
+	if opts.Format != "text" && opts.Format != "json" {
+		return nil, usageError(fmt.Sprintf("unknown format: %s", opts.Format))
+	}
+
+	source, err := readSource(flags, stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := opts.pipelineParams()
+	if err != nil {
+		return nil, err
+	}
+
+	compiler := config.Compiler{
+		Filename:    sourceName(flags),
+		OrbResolver: opts.orbResolver(),
+		OrbLock:     lock,
+		IncludeFS:   os.DirFS(opts.includeRoot(flags)),
+		Strict:      opts.Strict,
+	}
+
+	var compiled []byte
+	if opts.Graph != "" {
+		compiled, err = compiler.CompileGraph(source, params, config.GraphFormat(opts.Graph))
+	} else {
+		compiled, err = compiler.Compile(source, params)
+	}
+	if err != nil && opts.Format == "json" {
+		return nil, jsonError{err}
+	}
+
+	return compiled, err
+}
+
+// orbLock reads the lockfile given by --orb-lock. A lockfile that does not exist yet, or that is being
+// updated, starts out empty.
+func (opts compileFlags) orbLock() (*config.OrbLock, error) {
+	if opts.OrbLock == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(opts.OrbLock)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read orb lockfile: %w", err)
+	}
+	if opts.UpdateOrbLock {
+		data = nil
+	}
+
+	return config.ParseOrbLock(data)
+}
+
+// includeRoot returns the directory given by --include-root, defaulting to the directory of the config, the
+// directory itself when packing one, or the working directory when reading from stdin.
+func (opts compileFlags) includeRoot(flags *flag.FlagSet) string {
+	if opts.IncludeRoot != "" {
+		return opts.IncludeRoot
+	}
+
+	name := sourceName(flags)
+	if name == "<stdin>" {
+		return "."
+	}
+	if info, err := os.Stat(name); err == nil && info.IsDir() {
+		return name
+	}
+	return filepath.Dir(name)
+}
+
+func sourceName(flags *flag.FlagSet) string {
+	if name := flags.Arg(0); name != "" && name != "-" {
+		return name
+	}
+	return "<stdin>"
+}
+
+func readSource(flags *flag.FlagSet, stdin io.Reader) ([]byte, error) {
+	if flags.NArg() > 1 {
+		return nil, usageError(fmt.Sprintf("expected at most one config path but got %d", flags.NArg()))
+	}
+
+	if name := sourceName(flags); name != "<stdin>" {
+		if info, err := os.Stat(name); err == nil && info.IsDir() {
+			return config.Pack(os.DirFS(name))
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		return data, nil
+	}
+
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config from stdin: %w", err)
+	}
+	return data, nil
+}
+
+type stringsFlag []string
+
+func (values *stringsFlag) String() string {
+	return strings.Join(*values, ",")
+}
+
+func (values *stringsFlag) Set(value string) error {
+	*values = append(*values, value)
+	return nil
+}
+
+// pipelineFlags collects repeated --pipeline key=value flags. Pipeline values are strings, such as branch
+// names or revisions that may look like numbers, except for number which must be an integer.
+type pipelineFlags []paramKV
+
+func (values *pipelineFlags) String() string {
+	return (*paramFlags)(values).String()
+}
+
+func (values *pipelineFlags) Set(value string) error {
+	key, raw, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("pipeline value should be of form key=value, not %s", value)
+	}
+
+	if key != "number" {
+		*values = append(*values, paramKV{Key: key, Value: raw})
+		return nil
+	}
+
+	number, err := strconv.Atoi(raw)
+	if err != nil {
+		return fmt.Errorf("pipeline number should be an integer, not %s", raw)
+	}
+
+	*values = append(*values, paramKV{Key: key, Value: number})
+	return nil
+}
+
+type paramKV struct {
+	Key   string
+	Value any
+}
+
+// paramFlags collects repeated --param key=value flags. Integer and boolean values keep their
+// type so that they satisfy parameter declarations, everything else is passed as a string.
+type paramFlags []paramKV
+
+func (params *paramFlags) String() string {
+	values := make([]string, len(*params))
+	for i, kv := range *params {
+		values[i] = fmt.Sprintf("%s=%v", kv.Key, kv.Value)
+	}
+	return strings.Join(values, ",")
+}
+
+func (params *paramFlags) Set(value string) error {
+	key, raw, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("param should be of form key=value, not %s", value)
+	}
+
+	var parsed any = raw
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(raw), &node); err == nil && len(node.Content) == 1 && node.Content[0].Kind == yaml.ScalarNode {
+		var scalar any
+		if err := node.Content[0].Decode(&scalar); err == nil {
+			switch scalar.(type) {
+			case int, bool:
+				parsed = scalar
+			}
+		}
+	}
+
+	*params = append(*params, paramKV{Key: key, Value: parsed})
+	return nil
+}
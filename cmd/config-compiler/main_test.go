@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidmdm/yaml"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `version: 2.1
+
+parameters:
+  image:
+    type: string
+    default: go
+  count:
+    type: integer
+    default: 1
+  debug:
+    type: boolean
+    default: false
+
+jobs:
+  test:
+    docker:
+      - image: << pipeline.parameters.image >>
+    parallelism: << pipeline.parameters.count >>
+    steps:
+      - run: echo << pipeline.parameters.debug >>
+
+workflows:
+  main:
+    jobs:
+      - test
+`
+
+const orbSource = `version: 2.1
+orbs:
+  greet: acme/greet@1.0.0
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - greet/greet
+workflows:
+  main:
+    jobs:
+      - test
+`
+
+func TestRun(t *testing.T) {
+	t.Run("compile with params", func(t *testing.T) {
+		paramsFile := filepath.Join(t.TempDir(), "params.json")
+		require.NoError(t, os.WriteFile(paramsFile, []byte(`{"image": "node", "count": 2}`), 0o644))
+
+		var stdout, stderr bytes.Buffer
+		code := run(
+			[]string{"compile", "--params-file", paramsFile, "--param", "count=3", "--param", "debug=true"},
+			strings.NewReader(source),
+			&stdout,
+			&stderr,
+		)
+		require.Equal(t, 0, code, stderr.String())
+
+		var compiled struct {
+			Jobs map[string]struct {
+				Docker      []struct{ Image string } `yaml:"docker"`
+				Parallelism int                      `yaml:"parallelism"`
+				Steps       []struct {
+					Run struct{ Command string } `yaml:"run"`
+				} `yaml:"steps"`
+			} `yaml:"jobs"`
+		}
+		require.NoError(t, yaml.Unmarshal(stdout.Bytes(), &compiled))
+
+		job := compiled.Jobs["test"]
+		require.Equal(t, "node", job.Docker[0].Image)
+		require.Equal(t, 3, job.Parallelism)
+		require.Equal(t, "echo true", job.Steps[0].Run.Command)
+	})
+
+	t.Run("compile with pipeline values", func(t *testing.T) {
+		source := `version: 2.1
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - run: echo << pipeline.git.branch >> << pipeline.number >> "<< pipeline.git.tag >>"
+workflows:
+  main:
+    jobs:
+      - test
+`
+
+		var stdout, stderr bytes.Buffer
+		code := run(
+			[]string{"compile", "--pipeline", "git.branch=main", "--pipeline", "number=7"},
+			strings.NewReader(source),
+			&stdout,
+			&stderr,
+		)
+		require.Equal(t, 0, code, stderr.String())
+		require.Contains(t, stdout.String(), `command: echo main 7 ""`)
+
+		stdout.Reset()
+		code = run(
+			[]string{"compile", "--pipeline", "git.branch=0x10", "--pipeline", "git.tag=0012345", "--pipeline", "number=7"},
+			strings.NewReader(source),
+			&stdout,
+			&stderr,
+		)
+		require.Equal(t, 0, code, stderr.String())
+		require.Contains(t, stdout.String(), `command: echo 0x10 7 "0012345"`)
+
+		code = run([]string{"compile", "--pipeline", "number=seven"}, strings.NewReader(source), &stdout, &stderr)
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr.String(), "pipeline number should be an integer, not seven")
+	})
+
+	t.Run("compile with path mapping", func(t *testing.T) {
+		mappingFile := filepath.Join(t.TempDir(), "mapping.txt")
+		require.NoError(t, os.WriteFile(mappingFile, []byte("src/.* debug true\ndocker/.* image \"alpine\"\n"), 0o644))
+
+		var stdout, stderr bytes.Buffer
+		code := run(
+			[]string{"compile", "--path-mapping", mappingFile, "--changed", "src/main.go", "--changed", "docker/Dockerfile", "--param", "image=node"},
+			strings.NewReader(source),
+			&stdout,
+			&stderr,
+		)
+		require.Equal(t, 0, code, stderr.String())
+		require.Contains(t, stdout.String(), "command: echo true")
+		require.Contains(t, stdout.String(), "image: node")
+
+		stdout.Reset()
+		code = run([]string{"compile", "--changed", "src/main.go"}, strings.NewReader(source), &stdout, &stderr)
+		require.Equal(t, 2, code)
+	})
+
+	t.Run("compile to output file", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "compiled.yml")
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"compile", "-o", output}, strings.NewReader(source), &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		require.Empty(t, stdout.String())
+
+		data, err := os.ReadFile(output)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "image: go")
+	})
+
+	t.Run("validate reports errors", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"validate", "--param", "count=many"}, strings.NewReader(source), &stdout, &stderr)
+		require.Equal(t, 1, code)
+		require.Equal(
+			t,
+			"pipeline parameter error(s):\n  - <stdin>:8:5: type mismatch for param count: wanted integer but got string\n",
+			stderr.String(),
+		)
+	})
+
+	t.Run("validate reports json diagnostics", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"validate", "--format", "json", "--param", "count=many"}, strings.NewReader(source), &stdout, &stderr)
+		require.Equal(t, 1, code)
+		require.JSONEq(
+			t,
+			`{
+				"severity": "error",
+				"message": "pipeline parameter error(s):",
+				"path": "parameters",
+				"causes": [
+					{
+						"severity": "error",
+						"code": "param-type-mismatch",
+						"message": "type mismatch for param count: wanted integer but got string",
+						"path": "parameters.count",
+						"file": "<stdin>",
+						"line": 8,
+						"column": 5
+					}
+				]
+			}`,
+			stdout.String(),
+		)
+	})
+
+	t.Run("compile with orb dir", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "acme"), 0o755))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(dir, "acme", "greet@1.0.0.yml"),
+			[]byte("commands:\n  greet:\n    steps:\n      - run: echo hello\n"),
+			0o644,
+		))
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"compile", "--orb-dir", dir}, strings.NewReader(orbSource), &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		require.Contains(t, stdout.String(), "command: echo hello")
+	})
+
+	t.Run("compile with orb lock", func(t *testing.T) {
+		dir := t.TempDir()
+		orbFile := filepath.Join(dir, "acme", "greet@1.0.0.yml")
+		lockFile := filepath.Join(dir, "orbs.lock")
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(orbFile), 0o755))
+		require.NoError(t, os.WriteFile(orbFile, []byte("commands:\n  greet:\n    steps:\n      - run: echo hello\n"), 0o644))
+
+		compile := func(args ...string) (int, string) {
+			var stdout, stderr bytes.Buffer
+			args = append([]string{"compile", "--orb-dir", dir, "--orb-lock", lockFile}, args...)
+			return run(args, strings.NewReader(orbSource), &stdout, &stderr), stderr.String()
+		}
+
+		code, stderr := compile()
+		require.Equal(t, 0, code, stderr)
+		require.FileExists(t, lockFile)
+
+		require.NoError(t, os.WriteFile(orbFile, []byte("commands:\n  greet:\n    steps:\n      - run: echo changed\n"), 0o644))
+
+		code, stderr = compile()
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, "<stdin>:3:10: orb acme/greet@1.0.0 does not match the lockfile")
+
+		code, stderr = compile("--update-orb-lock")
+		require.Equal(t, 0, code, stderr)
+
+		code, stderr = compile()
+		require.Equal(t, 0, code, stderr)
+	})
+
+	t.Run("compile offline", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"compile", "--offline", "--orb-cache", t.TempDir()}, strings.NewReader(orbSource), &stdout, &stderr)
+		require.Equal(t, 1, code)
+		require.Equal(t, "<stdin>:3:10: failed to get orb acme/greet@1.0.0: orb not cached: acme/greet@1.0.0\n", stderr.String())
+	})
+
+	t.Run("compile a packed directory", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "jobs"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "@config.yml"), []byte("version: 2.1\nworkflows:\n  main:\n    jobs: [test]\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "jobs", "test.yml"), []byte("docker: [{image: go}]\nsteps: [checkout]\n"), 0o644))
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"validate", dir}, strings.NewReader(""), &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+
+		stdout.Reset()
+		code = run([]string{"pack", dir}, strings.NewReader(""), &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		require.Contains(t, stdout.String(), "jobs:\n  test:\n")
+	})
+
+	t.Run("compile with includes", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "scripts"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "scripts", "test.sh"), []byte("go test ./...\n"), 0o644))
+
+		configPath := filepath.Join(dir, "config.yml")
+		require.NoError(t, os.WriteFile(configPath, []byte("version: 2.1\njobs:\n  test:\n    docker: [{image: go}]\n    steps:\n      - run: <<include(scripts/test.sh)>>\nworkflows:\n  main:\n    jobs: [test]\n"), 0o644))
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"compile", configPath}, strings.NewReader(""), &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		require.Contains(t, stdout.String(), "go test ./...")
+
+		stdout.Reset()
+		code = run([]string{"compile", "--include-root", filepath.Join(dir, "scripts"), configPath}, strings.NewReader(""), &stdout, &stderr)
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr.String(), "cannot include scripts/test.sh: file not found")
+	})
+
+	t.Run("graph", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"graph", "--graph", "mermaid"}, strings.NewReader(source), &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		require.Equal(t, "flowchart LR\n  subgraph w0[\"main\"]\n    w0_j0[\"test\"]\n  end\n", stdout.String())
+
+		code = run([]string{"graph", "--graph", "svg"}, strings.NewReader(source), &stdout, &stderr)
+		require.Equal(t, 2, code)
+	})
+
+	t.Run("params", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"params"}, strings.NewReader(source), &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		require.Len(t, lines, 4)
+		require.True(t, strings.HasPrefix(lines[1], "count"))
+		require.True(t, strings.HasPrefix(lines[2], "debug"))
+		require.True(t, strings.HasPrefix(lines[3], "image"))
+	})
+
+	t.Run("invalid param flag", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"compile", "--param", "novalue"}, strings.NewReader(source), &stdout, &stderr)
+		require.Equal(t, 2, code)
+	})
+
+	t.Run("unknown command", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 2, run([]string{"process"}, strings.NewReader(source), &stdout, &stderr))
+	})
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// CacheKeyErr reports a cache key template that CircleCI would fail to render.
+type CacheKeyErr struct {
+	Key string
+	Err error
+}
+
+func (err CacheKeyErr) Error() string {
+	return fmt.Sprintf("invalid cache key %q: %v", err.Key, err.Err)
+}
+
+func (err CacheKeyErr) Unwrap() error { return err.Err }
+
+func (CacheKeyErr) Code() ErrorCode { return CodeInvalidCacheKey }
+
+// CacheKey is a parsed save_cache or restore_cache key, made of literal text and the {{ }} templates that
+// CircleCI renders at runtime.
+type CacheKey []cacheKeyPart
+
+type cacheKeyPart struct {
+	literal string
+	// action is the name of the template, such as checksum or .Environment.HOME, and is empty for literal text.
+	action string
+	arg    string
+}
+
+// cacheKeyFields are the fields available to cache key templates.
+var cacheKeyFields = []string{".Branch", ".BuildNum", ".Revision", ".CheckoutKey", ".Environment"}
+
+// ParseCacheKey parses a cache key, reporting malformed braces and unknown functions or fields.
+func ParseCacheKey(key string) (CacheKey, error) {
+	var (
+		result CacheKey
+		rest   = key
+	)
+
+	keyErr := func(format string, args ...any) error {
+		return CacheKeyErr{Key: key, Err: fmt.Errorf(format, args...)}
+	}
+
+	for rest != "" {
+		start, end := strings.Index(rest, "{{"), strings.Index(rest, "}}")
+		if end >= 0 && (start < 0 || end < start) {
+			return nil, keyErr("unexpected }} without a matching {{")
+		}
+		if start < 0 {
+			result = append(result, cacheKeyPart{literal: rest})
+			break
+		}
+		if start > 0 {
+			result = append(result, cacheKeyPart{literal: rest[:start]})
+		}
+		if end < 0 {
+			return nil, keyErr("unclosed {{")
+		}
+
+		action := strings.TrimSpace(rest[start+2 : end])
+		if strings.Contains(action, "{{") {
+			return nil, keyErr("unclosed {{")
+		}
+
+		part, err := parseCacheKeyAction(action)
+		if err != nil {
+			return nil, keyErr("%v", err)
+		}
+		result = append(result, part)
+
+		rest = rest[end+2:]
+	}
+
+	return result, nil
+}
+
+func parseCacheKeyAction(action string) (cacheKeyPart, error) {
+	if action == "" {
+		return cacheKeyPart{}, errors.New("empty template {{ }}")
+	}
+
+	name, arg, _ := strings.Cut(action, " ")
+	arg = strings.TrimSpace(arg)
+
+	if strings.HasPrefix(name, ".") {
+		if arg != "" {
+			return cacheKeyPart{}, fmt.Errorf("field %s takes no argument", name)
+		}
+		if env, ok := strings.CutPrefix(name, ".Environment."); ok && env != "" && !strings.Contains(env, ".") {
+			return cacheKeyPart{action: ".Environment", arg: env}, nil
+		}
+		if name == ".Environment" || strings.HasPrefix(name, ".Environment.") {
+			return cacheKeyPart{}, fmt.Errorf("field %s must name a single variable such as .Environment.HOME", name)
+		}
+		if !slices.Contains(cacheKeyFields, name) {
+			return cacheKeyPart{}, unknownCacheKeyErr("field", name, cacheKeyFields)
+		}
+		return cacheKeyPart{action: name}, nil
+	}
+
+	switch name {
+	case "checksum":
+		filename, err := strconv.Unquote(arg)
+		if err != nil || filename == "" {
+			return cacheKeyPart{}, errors.New(`checksum requires a filename in double quotes, such as {{ checksum "go.sum" }}`)
+		}
+		return cacheKeyPart{action: name, arg: filename}, nil
+	case "epoch", "arch":
+		if arg != "" {
+			return cacheKeyPart{}, fmt.Errorf("function %s takes no argument", name)
+		}
+		return cacheKeyPart{action: name}, nil
+	}
+
+	return cacheKeyPart{}, unknownCacheKeyErr("function", name, []string{"checksum", "epoch", "arch"})
+}
+
+func unknownCacheKeyErr(kind, name string, known []string) error {
+	if suggestion := suggest(name, known); suggestion != "" {
+		return fmt.Errorf("unknown %s %s, did you mean %s?", kind, name, suggestion)
+	}
+	return fmt.Errorf("unknown %s %s: expected one of %s", kind, name, strings.Join(known, ", "))
+}
+
+// CacheKeyValues are the values a cache key is rendered with. Values that are not set render empty, except
+// for Epoch which defaults to the current time.
+type CacheKeyValues struct {
+	Branch      string
+	BuildNum    int
+	Revision    string
+	CheckoutKey string
+	Environment map[string]string
+	Epoch       time.Time
+	Arch        string
+	// Files holds the files checksums are computed from, typically the working directory of the job.
+	Files fs.FS
+}
+
+// Render renders the key as CircleCI would with the given values, such that keys computed locally can be
+// compared with those of a job when debugging cache misses.
+func (key CacheKey) Render(values CacheKeyValues) (string, error) {
+	var result strings.Builder
+
+	for _, part := range key {
+		switch part.action {
+		case "":
+			result.WriteString(part.literal)
+		case ".Branch":
+			result.WriteString(values.Branch)
+		case ".BuildNum":
+			result.WriteString(strconv.Itoa(values.BuildNum))
+		case ".Revision":
+			result.WriteString(values.Revision)
+		case ".CheckoutKey":
+			result.WriteString(values.CheckoutKey)
+		case ".Environment":
+			result.WriteString(values.Environment[part.arg])
+		case "arch":
+			result.WriteString(values.Arch)
+		case "epoch":
+			epoch := values.Epoch
+			if epoch.IsZero() {
+				epoch = time.Now()
+			}
+			result.WriteString(strconv.FormatInt(epoch.Unix(), 10))
+		case "checksum":
+			checksum, err := fileChecksum(values.Files, part.arg)
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(checksum)
+		}
+	}
+
+	return result.String(), nil
+}
+
+// RenderCacheKey parses and renders a cache key with the given values.
+func RenderCacheKey(key string, values CacheKeyValues) (string, error) {
+	parsed, err := ParseCacheKey(key)
+	if err != nil {
+		return "", err
+	}
+	return parsed.Render(values)
+}
+
+// fileChecksum returns the base64 encoded SHA-256 digest of the file, as the checksum template does.
+func fileChecksum(files fs.FS, filename string) (string, error) {
+	if files == nil {
+		return "", fmt.Errorf("cannot checksum %s: no files configured", filename)
+	}
+	data, err := fs.ReadFile(files, strings.TrimPrefix(filename, "./"))
+	if err != nil {
+		return "", fmt.Errorf("cannot checksum %s: %w", filename, err)
+	}
+	digest := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(digest[:]), nil
+}
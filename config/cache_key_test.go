@@ -0,0 +1,74 @@
+package config_test
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCacheKey(t *testing.T) {
+	valid := []string{
+		"go-mod",
+		`go-mod-{{ checksum "go.sum" }}`,
+		`v1-{{ .Branch }}-{{ .Revision }}-{{ .BuildNum }}-{{ .CheckoutKey }}`,
+		`{{ arch }}-{{ epoch }}-{{ .Environment.CACHE_VERSION }}`,
+		`deps-{{checksum "./package-lock.json"}}`,
+	}
+	for _, key := range valid {
+		t.Run(key, func(t *testing.T) {
+			_, err := config.ParseCacheKey(key)
+			require.NoError(t, err)
+		})
+	}
+
+	invalid := []struct {
+		Key string
+		Err string
+	}{
+		{Key: "go-{{ checksum \"go.sum\"", Err: "unclosed {{"},
+		{Key: "go-}}", Err: "unexpected }} without a matching {{"},
+		{Key: "go-{{ }}", Err: "empty template {{ }}"},
+		{Key: `go-{{ checksums "go.sum" }}`, Err: "unknown function checksums, did you mean checksum?"},
+		{Key: "go-{{ hash }}", Err: "unknown function hash: expected one of checksum, epoch, arch"},
+		{Key: "go-{{ .Brnch }}", Err: "unknown field .Brnch, did you mean .Branch?"},
+		{Key: "go-{{ checksum go.sum }}", Err: `checksum requires a filename in double quotes, such as {{ checksum "go.sum" }}`},
+		{Key: "go-{{ epoch 1 }}", Err: "function epoch takes no argument"},
+		{Key: "go-{{ .Environment }}", Err: "field .Environment must name a single variable such as .Environment.HOME"},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.Key, func(t *testing.T) {
+			_, err := config.ParseCacheKey(tc.Key)
+			require.EqualError(t, err, fmt.Sprintf("invalid cache key %q: %s", tc.Key, tc.Err))
+			require.Equal(t, config.CodeInvalidCacheKey, config.Diagnose(err).Code)
+		})
+	}
+}
+
+func TestRenderCacheKey(t *testing.T) {
+	values := config.CacheKeyValues{
+		Branch:      "main",
+		BuildNum:    42,
+		Revision:    "abc123",
+		Environment: map[string]string{"CACHE_VERSION": "v2"},
+		Epoch:       time.Unix(1700000000, 0),
+		Arch:        "arch1-linux-amd64-6_106",
+		Files:       fstest.MapFS{"go.sum": {Data: []byte("sum\n")}},
+	}
+
+	key, err := config.RenderCacheKey(
+		`{{ .Environment.CACHE_VERSION }}-{{ .Branch }}-{{ .Revision }}-{{ .BuildNum }}-{{ arch }}-{{ epoch }}-{{ checksum "./go.sum" }}`,
+		values,
+	)
+	require.NoError(t, err)
+	require.Equal(t, "v2-main-abc123-42-arch1-linux-amd64-6_106-1700000000-xfyDwB6SQERSuYZSfSORQMz5pIuI4MJo+/OMLhQp6ck=", key)
+
+	_, err = config.RenderCacheKey(`{{ checksum "missing.lock" }}`, values)
+	require.ErrorContains(t, err, "cannot checksum missing.lock")
+
+	_, err = config.RenderCacheKey(`{{ .Bogus }}`, values)
+	require.Error(t, err)
+}
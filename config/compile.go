@@ -1,9 +1,10 @@
 package config
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"reflect"
+	"io/fs"
 	"strings"
 
 	"github.com/davidmdm/yaml"
@@ -25,11 +26,18 @@ type ApprovalJob struct {
 
 type MatrixJob struct {
 	MatrixValues []KV
-	Job          *Job
+	// Name is the name of the compiled job, when set by its matrix.
+	Name string
+	Job  *Job
+
+	// workflow is the name of the workflow the job was first compiled for.
+	workflow string
 }
 
 type WFJob struct {
-	Requires StringList
+	// Names are the names by which other jobs of the workflow may require the job.
+	Names    []string
+	Requires Requirements
 	Contexts StringList
 	Filters  Filters
 	*Job
@@ -39,12 +47,13 @@ type compilerState struct {
 	Jobs      map[string][]MatrixJob
 	Workflows map[string][]WFJob
 	Approvals map[string][]ApprovalJob
+	Triggers  map[string][]Trigger
 }
 
 type Compiler struct {
 	root struct {
 		Setup     bool               `yaml:"setup"`
-		Orbs      map[string]string  `yaml:"orbs"`
+		Orbs      map[string]RawNode `yaml:"orbs"`
 		Workflows map[string]RawNode `yaml:"workflows"`
 		Jobs      map[string]RawNode `yaml:"jobs"`
 		Commands  map[string]RawNode `yaml:"commands"`
@@ -55,31 +64,86 @@ type Compiler struct {
 
 	state compilerState
 
-	// GetOrbSource defines how orb data will be fetched.
+	// OrbResolver defines how orb data will be fetched. Defaults to the circleci.com GraphQL API.
+	OrbResolver OrbResolver
+
+	// GetOrbSource defines how orb data will be fetched when OrbResolver is nil.
 	GetOrbSource func(ref string) (string, error)
+
+	// Filename is the name of the source config, used to locate errors. Errors within orbs are located
+	// by the orb reference instead.
+	Filename string
+
+	// ContinuationFilename is the name of the continuation config given to CompileContinuation, used to
+	// locate its errors.
+	ContinuationFilename string
+
+	// IncludeFS is the root that <<include(path)>> directives are resolved against, typically the directory of
+	// the source config. Configs that include files fail to compile when it is nil.
+	IncludeFS fs.FS
+
+	// Strict reports the keys of jobs, commands, executors and workflows that are not recognized, such as
+	// typos, instead of dropping them from the compiled config.
+	Strict bool
+
+	// OrbLock, when set, is used to verify the content of orbs, which must be pinned to an exact version.
+	// After a successful compilation it holds the orbs of the config, ready to be written back to the lockfile.
+	OrbLock *OrbLock
 }
 
+// Compile compiles the source config using the given pipeline values, keyed as referenced within the config
+// such as {"git": {"branch": "main"}}. Values that are not given default to those of PipelineValues. Errors
+// that originate from the source carry their position, which can be retrieved with SourceErrors.
 func (c Compiler) Compile(source []byte, pipelineParams map[string]any) ([]byte, error) {
-	if c.GetOrbSource == nil {
-		c.GetOrbSource = GetOrbSource
+	compiled, err := c.compileConfig(source, pipelineParams)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(compiled)
+}
+
+// compileConfig compiles the source config, locating errors within the compiler's Filename.
+func (c Compiler) compileConfig(source []byte, pipelineParams map[string]any) (*Config, error) {
+	result, err := c.compileSource(source, pipelineParams)
+	if err != nil {
+		return nil, setErrFile(err, c.Filename)
+	}
+	return result, nil
+}
+
+func (c Compiler) compileSource(source []byte, pipelineParams map[string]any) (*Config, error) {
+	if c.OrbResolver == nil {
+		if c.GetOrbSource != nil {
+			c.OrbResolver = OrbResolverFunc(c.GetOrbSource)
+		} else {
+			c.OrbResolver = GraphQLResolver{}
+		}
 	}
 
 	c.state = compilerState{
 		Jobs:      map[string][]MatrixJob{},
 		Workflows: map[string][]WFJob{},
 		Approvals: map[string][]ApprovalJob{},
+		Triggers:  map[string][]Trigger{},
 	}
 
 	var rootNode RawNode
 	if err := yaml.Unmarshal(source, &rootNode); err != nil {
-		return nil, fmt.Errorf("invalid source: %v", err)
+		return nil, withContext("invalid source", "", yamlErr(err))
+	}
+	if rootNode.Node == nil {
+		return nil, withContext("invalid source", "", errAtPosition(Position{Line: 1, Column: 1}, withCode(CodeEmptyConfig, errors.New("config is empty"))))
 	}
 
 	resolveAliases(rootNode.Node)
 
+	if err := resolveIncludes(c.IncludeFS, rootNode.Node); err != nil {
+		return nil, err
+	}
+
 	parameters, err := getParametersFromRootNode(rootNode.Node)
 	if err != nil {
-		return nil, fmt.Errorf("error processing pipeline parameters: %w", err)
+		return nil, withContext("error processing pipeline parameters", "parameters", err)
 	}
 
 	var pipelineParameters map[string]any
@@ -88,62 +152,60 @@ func (c Compiler) Compile(source []byte, pipelineParams map[string]any) ([]byte,
 		if ok {
 			params, ok := value.(map[string]any)
 			if !ok {
-				return nil, fmt.Errorf("failed to parse provided pipeline parameters: `parameters` key must have a map[string]any value")
+				return nil, withCode(CodeInvalidPipelineArgs, fmt.Errorf("failed to parse provided pipeline parameters: `parameters` key must have a map[string]any value"))
 			}
 			pipelineParameters = params
 		}
 	}
 
-	if errs := validateParameters(parameters, toParamValues(pipelineParameters)); len(errs) > 0 {
-		return nil, PrettyErr{Message: "pipeline parameter error(s):", Errors: errs}
+	if errs := validateParameters(parameters, toParamValues(pipelineParameters), positionOf(rootNode.Node)); len(errs) > 0 {
+		return nil, withContext("", "parameters", PrettyErr{Message: "pipeline parameter error(s):", Errors: errs})
 	}
 
-	if pipelineParams == nil {
-		pipelineParams = map[string]any{"parameters": parameters.JoinDefaults(map[string]any{})}
+	pipelineParams = withPipelineDefaults(pipelineParams)
+	pipelineParams["parameters"] = parameters.JoinDefaults(pipelineParameters)
+
+	if node, err := applyPipelineParams[RawNode](rootNode.Node, pipelineParams); err != nil {
+		return nil, err
 	} else {
-		pipelineParams["parameters"] = parameters.JoinDefaults(pipelineParameters)
+		rootNode = *node
 	}
 
-	if len(pipelineParams) > 0 {
-		if node, err := applyPipelineParams[RawNode](rootNode.Node, pipelineParams); err != nil {
-			return nil, err
-		} else {
-			rootNode = *node
-		}
+	if err := decode(rootNode.Node, &c.root); err != nil {
+		return nil, err
 	}
 
-	if err := rootNode.Decode(&c.root); err != nil {
+	if err := c.validateDeclarations(); err != nil {
 		return nil, err
 	}
 
+	if c.Strict {
+		if err := c.strictKeys(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Shortcircuit if no workflows or build jobs
 	if _, ok := c.root.Jobs["build"]; len(c.root.Workflows) == 0 && !ok {
-		return nil, errors.New("config contains no workflows or build jobs")
+		return nil, errAt(rootNode.Node, withCode(CodeNoWorkflows, errors.New("config contains no workflows or build jobs")))
 	}
 
-	c.orbs = make(Orbs, len(c.root.Orbs))
-
-	for name, orb := range c.root.Orbs {
-		src, err := c.GetOrbSource(orb)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get orb: %s", orb)
-		}
-
-		src = strings.ReplaceAll(src, "{{", "<<")
-		src = strings.ReplaceAll(src, "}}", ">>")
-
-		var raw Orb
-		if err := yaml.Unmarshal([]byte(src), &raw); err != nil {
-			return nil, fmt.Errorf("failed to parse orb %s: %w", name, err)
-		}
-		c.orbs[name] = raw
+	locked, err := c.loadOrbs()
+	if err != nil {
+		return nil, err
 	}
 
 	if err := c.processWorkflows(); err != nil {
 		return nil, err
 	}
 
-	return yaml.Marshal(c.compile())
+	result := c.compile()
+
+	if c.OrbLock != nil {
+		c.OrbLock.Orbs = locked
+	}
+
+	return &result, nil
 }
 
 func (c Compiler) compile() Config {
@@ -154,53 +216,51 @@ func (c Compiler) compile() Config {
 		Workflows: map[string]Workflow{},
 	}
 
-	nameMapping := map[string][]string{}
-
 	for name, matrixJobs := range c.state.Jobs {
 		jobTotal := len(matrixJobs)
-		compiledNames := make([]string, jobTotal)
 		for i, matrixJob := range matrixJobs {
 
 			job := matrixJob.Job
 
-			if jobTotal == 1 {
+			switch {
+			case matrixJob.Name != "":
+				job.name = matrixJob.Name
+			case jobTotal == 1:
 				job.name = name
-			} else {
+			default:
 				job.name = fmt.Sprintf("%s-%d", name, i+1)
 			}
 
-			matrixSuffix := func() string {
-				if matrixJob.MatrixValues == nil {
-					return ""
-				}
-				values := []string{}
-				for _, kv := range matrixJob.MatrixValues {
-					values = append(values, fmt.Sprintf("%v", kv.Value))
-				}
-				return strings.Join(values, "-")
-			}()
-
-			if matrixSuffix != "" {
-				job.name = name + "-" + matrixSuffix
-			}
-
-			compiledNames[i] = job.name
-
 			// zero out reusable fields
 			job.Parameters = nil
 			job.Executor = JobExecutor{}
 			compiled.Jobs[job.name] = *job
 		}
-		nameMapping[name] = compiledNames
 	}
 
 	for name, jobs := range c.state.Workflows {
+		nameMapping := map[string][]string{}
+		for _, j := range jobs {
+			for _, required := range j.Names {
+				if !slices.Contains(nameMapping[required], j.name) {
+					nameMapping[required] = append(nameMapping[required], j.name)
+				}
+			}
+		}
+		for _, approval := range c.state.Approvals[name] {
+			nameMapping[approval.Job.Name()] = []string{approval.Job.Name()}
+		}
+
 		workflowJobs := make([]WorkflowJob, len(jobs))
 		for i, j := range jobs {
 
-			var requires []string
-			for _, name := range j.Requires {
-				requires = append(requires, nameMapping[name]...)
+			var requires Requirements
+			for _, requirement := range j.Requires {
+				for _, required := range nameMapping[requirement.Job] {
+					if !slices.Contains(requires.Jobs(), required) {
+						requires = append(requires, Requirement{Job: required, Status: requirement.Status})
+					}
+				}
 			}
 
 			workflowJobs[i] = WorkflowJob{
@@ -215,12 +275,14 @@ func (c Compiler) compile() Config {
 			}
 		}
 
-		workflow := Workflow{Jobs: workflowJobs}
+		workflow := Workflow{Triggers: c.state.Triggers[name], Jobs: workflowJobs}
 
 		for i, approval := range c.state.Approvals[name] {
-			var requires []string
-			for _, name := range approval.Job.Requires {
-				requires = append(requires, nameMapping[name]...)
+			var requires Requirements
+			for _, requirement := range approval.Job.Requires {
+				for _, required := range nameMapping[requirement.Job] {
+					requires = append(requires, Requirement{Job: required, Status: requirement.Status})
+				}
 			}
 			approval.Job.Requires = requires
 			workflow.Jobs = slices.Insert(workflow.Jobs, approval.Offset+i, approval.Job)
@@ -235,7 +297,7 @@ func (c Compiler) compile() Config {
 func (c Compiler) processWorkflows() error {
 	if len(c.root.Workflows) == 0 {
 		if err := c.processWorkflow("workflow", &Workflow{Jobs: []WorkflowJob{{Key: "build"}}}); err != nil {
-			return fmt.Errorf("error processing build job: %w", err)
+			return withContext("error processing build job", "", err)
 		}
 	}
 
@@ -244,9 +306,12 @@ func (c Compiler) processWorkflows() error {
 	// First pass through workflows simply validates the workflows reference valid jobs, and that the
 	// parameters are aligned. It only evaluates workflows that will not be skipped. If a workflow is valid
 	// it is written to the compiled version for future processing.
-	for name, workflow := range c.root.Workflows {
-		if err := c.processWorkflowNode(name, workflow); err != nil {
-			errs = append(errs, fmt.Errorf("workflow %s: %w", name, err))
+	names := maps.Keys(c.root.Workflows)
+	slices.Sort(names)
+
+	for _, name := range names {
+		if err := c.processWorkflowNode(name, c.root.Workflows[name]); err != nil {
+			errs = append(errs, withContext("workflow "+name, "workflows."+name, err))
 		}
 	}
 	if len(errs) > 0 {
@@ -272,14 +337,39 @@ func (c *Compiler) processWorkflow(name string, workflow *Workflow) error {
 		return nil
 	}
 
+	if len(workflow.Triggers) > 0 {
+		c.state.Triggers[name] = workflow.Triggers
+	}
+
 	var (
 		offset           int
 		workflowJobNames = make([]string, 0, len(workflow.Jobs))
+		instances        = make([][]matrixInstance, len(workflow.Jobs))
 	)
 
-	for _, workflowJob := range workflow.Jobs {
+	for i, workflowJob := range workflow.Jobs {
 		workflowJobNames = append(workflowJobNames, workflowJob.Name())
 
+		if workflowJob.Type == "approval" {
+			instances[i] = []matrixInstance{{Requires: workflowJob.Requires}}
+			continue
+		}
+
+		jobInstances, err := expandMatrix(workflowJob)
+		if err != nil {
+			return withContext("job "+workflowJob.Key, fmt.Sprintf("jobs[%d]", i), err)
+		}
+		for _, instance := range jobInstances {
+			workflowJobNames = append(workflowJobNames, instance.Names...)
+		}
+		instances[i] = jobInstances
+	}
+
+	if errs := validateMatrixNames(workflow, instances); len(errs) > 0 {
+		return PrettyErr{Message: "matrix error(s):", Errors: errs}
+	}
+
+	for i, workflowJob := range workflow.Jobs {
 		if workflowJob.Type == "approval" {
 			c.state.Approvals[name] = append(c.state.Approvals[name], ApprovalJob{
 				Offset: offset,
@@ -288,38 +378,41 @@ func (c *Compiler) processWorkflow(name string, workflow *Workflow) error {
 			continue
 		}
 
+		var jobOrb string
+
 		jobNode, ok := c.root.Jobs[workflowJob.Key]
 		if !ok {
 			jobNode, ok = c.orbs.GetJobNode(workflowJob.Key)
+			jobOrb, _, _ = strings.Cut(workflowJob.Key, "/")
 			if !ok {
-				return fmt.Errorf("job %s not found", workflowJob.Key)
+				err := errAtPosition(workflowJob.pos, withCode(CodeJobNotFound, fmt.Errorf("job %s not found", workflowJob.Key)))
+				return withContext("", fmt.Sprintf("jobs[%d]", i), err)
 			}
 		}
 
-		matrixKVs := flattenKeyedMatrix(workflowJob.Matrix.Parameters)
-
-		if len(matrixKVs) == 0 {
-			matrixKVs = make([][]KV, 1)
-		}
-
-		for _, matrix := range matrixKVs {
+		for _, instance := range instances[i] {
 			offset += 1
-			if err := c.processJob(name, workflowJob, matrix, jobNode.Node); err != nil {
-				return fmt.Errorf("job %s: %v", workflowJob.Key, err)
+			if err := c.processJob(name, workflowJob, instance, jobNode.Node, jobOrb); err != nil {
+				return withContext("job "+workflowJob.Key, fmt.Sprintf("jobs[%d]", i), err)
 			}
 		}
 	}
 
 	var errs []error
 
-	for _, wfJob := range workflow.Jobs {
-		for _, required := range wfJob.Requires {
-			if !slices.Contains(workflowJobNames, required) {
-				errs = append(errs, fmt.Errorf("job %s cannot require %s: no job named %s in workflow", wfJob.Name(), required, required))
+	for i, wfJob := range workflow.Jobs {
+		for _, instance := range instances[i] {
+			for _, required := range instance.Requires.Jobs() {
+				if !slices.Contains(workflowJobNames, required) {
+					err := withCode(CodeUnknownRequirement, fmt.Errorf("job %s cannot require %s: no job named %s in workflow", wfJob.Name(), required, required))
+					errs = append(errs, withContext("", fmt.Sprintf("jobs[%d].requires", i), errAtPosition(wfJob.pos, err)))
+				}
 			}
 		}
 	}
 
+	errs = append(errs, validateRequires(workflow, instances)...)
+
 	if len(errs) > 0 {
 		return PrettyErr{Message: "job requirement error(s):", Errors: errs}
 	}
@@ -327,10 +420,15 @@ func (c *Compiler) processWorkflow(name string, workflow *Workflow) error {
 	return nil
 }
 
-func (c *Compiler) processJob(workflowName string, workflowJob WorkflowJob, matrix []KV, jobNode *yaml.Node) error {
+// processJob compiles the job defined by jobNode for the given instance of the workflow job. The jobOrb
+// is the name of the orb the job is defined in, or empty for jobs defined in the config itself.
+func (c *Compiler) processJob(workflowName string, workflowJob WorkflowJob, instance matrixInstance, jobNode *yaml.Node, jobOrb string) error {
+	jobFile := c.orbs[jobOrb].ref
+	matrix := instance.Values
+
 	parameters, err := getParametersFromNode(jobNode)
 	if err != nil {
-		return err
+		return setErrFile(err, jobFile)
 	}
 
 	paramValues := func() ParamValues {
@@ -345,32 +443,42 @@ func (c *Compiler) processJob(workflowName string, workflowJob WorkflowJob, matr
 		return ParamValues{Values: values}
 	}()
 
-	if errs := validateParameters(parameters, paramValues); len(errs) > 0 {
+	if errs := validateParameters(parameters, paramValues, workflowJob.pos); len(errs) > 0 {
 		return PrettyErr{Message: "parameter error(s):", Errors: errs}
 	}
 
 	job, err := applyParams[Job](jobNode, parameters.JoinDefaults(paramValues.AsMap()))
 	if err != nil {
-		return err
+		return setErrFile(err, jobFile)
+	}
+
+	for i := range job.Steps {
+		job.Steps[i].file = jobFile
+		job.Steps[i].orb = jobOrb
 	}
 
 	if job.Executor.Name != "" {
+		var exFile string
+
 		exNode, ok := c.root.Executors[job.Executor.Name]
-		if !ok {
-			exNode, ok = c.orbs.GetExecutorNode(job.Executor.Name)
+		if jobOrb != "" || !ok {
+			var exOrb string
+			exNode, exOrb, ok = c.orbs.GetExecutorNode(jobOrb, job.Executor.Name)
+			exFile = c.orbs[exOrb].ref
 			if !ok {
-				return fmt.Errorf("executor not found: %s", job.Executor.Name)
+				err := withCode(CodeExecutorNotFound, fmt.Errorf("executor not found: %s", job.Executor.Name))
+				return setErrFile(errAtPosition(job.Executor.pos, err), jobFile)
 			}
 		}
 
 		parameters, err := getParametersFromNode(exNode.Node)
 		if err != nil {
-			return err
+			return setErrFile(err, exFile)
 		}
 
 		ex, err := applyParams[Executor](exNode.Node, parameters.JoinDefaults(job.Executor.ParamValues.AsMap()))
 		if err != nil {
-			return err
+			return setErrFile(err, exFile)
 		}
 		job.InlineExecutor = InlineExecutor(*ex)
 	}
@@ -386,26 +494,41 @@ func (c *Compiler) processJob(workflowName string, workflowJob WorkflowJob, matr
 	}
 
 	if len(job.Steps) == 0 {
-		return errors.New("steps are required but got none")
+		return setErrFile(errAt(jobNode, withCode(CodeNoSteps, errors.New("steps are required but got none"))), jobFile)
 	}
 
 	jobName := workflowJob.Name()
 
 	jobIdx := slices.IndexFunc(c.state.Jobs[jobName], func(j MatrixJob) bool {
-		return reflect.DeepEqual(job, j.Job)
+		return j.Name == instance.Name && equalJobs(job, j.Job)
 	})
 
+	// Matrix jobs are compiled under the name of their instance, which workflows passing other arguments to
+	// the job would share.
+	if jobIdx < 0 && instance.Name != "" {
+		if i := slices.IndexFunc(c.state.Jobs[jobName], func(j MatrixJob) bool { return j.Name == instance.Name }); i >= 0 {
+			err := withCode(CodeInvalidMatrix, fmt.Errorf(
+				"job name %s is used by workflows %s and %s with different arguments",
+				instance.Name, c.state.Jobs[jobName][i].workflow, workflowName,
+			))
+			return withContext("", "name", errAtPosition(workflowJob.pos, err))
+		}
+	}
+
 	if jobIdx < 0 {
 		c.state.Jobs[jobName] = append(c.state.Jobs[jobName], MatrixJob{
 			MatrixValues: matrix,
+			Name:         instance.Name,
 			Job:          job,
+			workflow:     workflowName,
 		})
 	} else {
 		job = c.state.Jobs[jobName][jobIdx].Job
 	}
 
 	c.state.Workflows[workflowName] = append(c.state.Workflows[workflowName], WFJob{
-		Requires: workflowJob.Requires,
+		Names:    instance.Names,
+		Requires: instance.Requires,
 		Contexts: workflowJob.Context,
 		Filters:  workflowJob.Filters,
 		Job:      job,
@@ -420,12 +543,19 @@ func (c Compiler) expandMultiStep(orbCtx string, steps []Step) ([]Step, error) {
 		errs   []error
 	)
 	for i, substep := range steps {
-		if substeps, err := c.expandStep(orbCtx, substep); err != nil {
+		stepCtx := orbCtx
+		if substep.orb != "" {
+			stepCtx = substep.orb
+		}
+		if substeps, err := c.expandStep(stepCtx, substep); err != nil {
 			stepName := substep.Type
-			if orbCtx != "" {
-				stepName = orbCtx + "/" + stepName
+			if stepCtx != "" {
+				stepName = stepCtx + "/" + stepName
 			}
-			errs = append(errs, fmt.Errorf("step %d: %s: %w", i, stepName, err))
+			if substep.file != "" {
+				err = setErrFile(err, substep.file)
+			}
+			errs = append(errs, withContext(fmt.Sprintf("step %d: %s", i, stepName), fmt.Sprintf("steps[%d]", i), err))
 		} else {
 			result = append(result, substeps...)
 		}
@@ -442,94 +572,147 @@ func (c Compiler) expandStep(orbCtx string, step Step) ([]Step, error) {
 		if step.When == nil || !step.When.Condition.Evaluate() {
 			return nil, nil
 		}
-		return c.expandMultiStep(orbCtx, step.When.Steps)
+		return c.expandMultiStep(orbCtx, inFile(step.When.Steps, step.file))
 	case step.Type == "unless":
 		if step.Unless == nil || step.Unless.Condition.Evaluate() {
 			return nil, nil
 		}
-		return c.expandMultiStep(orbCtx, step.Unless.Steps)
+		return c.expandMultiStep(orbCtx, inFile(step.Unless.Steps, step.file))
 	case slices.Contains(stepCmds, step.Type):
 		return []Step{step}, nil
 	default:
+		var cmdFile string
+
 		cmdNode, ok := c.root.Commands[step.Type]
-		if !ok {
+		if orbCtx != "" || !ok {
 			cmdNode, orbCtx, ok = c.orbs.GetCommandNode(orbCtx, step.Type)
 			if !ok {
-				return nil, fmt.Errorf("command not found")
+				return nil, errAtPosition(step.pos, withCode(CodeCommandNotFound, errors.New("command not found")))
 			}
+			cmdFile = c.orbs[orbCtx].ref
 		}
 
 		parameters, err := getParametersFromNode(cmdNode.Node)
 		if err != nil {
-			return nil, err
+			return nil, setErrFile(err, cmdFile)
 		}
 
-		if errs := validateParameters(parameters, step.Params); len(errs) > 0 {
+		if errs := validateParameters(parameters, step.Params, step.pos); len(errs) > 0 {
 			return nil, PrettyErr{Message: fmt.Sprintf("parameter error(s) invoking command %s", step.Type), Errors: errs}
 		}
 
 		cmd, err := applyParams[Command](cmdNode.Node, parameters.JoinDefaults(step.Params.AsMap()))
 		if err != nil {
-			return nil, err
+			return nil, setErrFile(err, cmdFile)
 		}
 
-		return c.expandMultiStep(orbCtx, cmd.Steps)
+		return c.expandMultiStep(orbCtx, inFile(cmd.Steps, cmdFile))
+	}
+}
+
+// inFile marks steps without a file as belonging to file.
+func inFile(steps []Step, file string) []Step {
+	if file == "" {
+		return steps
+	}
+	for i := range steps {
+		if steps[i].file == "" {
+			steps[i].file = file
+		}
 	}
+	return steps
+}
+
+// equalJobs reports whether two compiled jobs are identical, disregarding where they were declared.
+func equalJobs(a, b *Job) bool {
+	rawA, errA := yaml.Marshal(a)
+	rawB, errB := yaml.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(rawA, rawB)
 }
 
 type Orb struct {
+	Orbs      map[string]RawNode `yaml:"orbs"`
 	Jobs      map[string]RawNode `yaml:"jobs"`
 	Commands  map[string]RawNode `yaml:"commands"`
 	Executors map[string]RawNode `yaml:"executors"`
+
+	// ref is the reference the orb was fetched by. Inline orbs take the reference of the orb they are
+	// declared in, and are empty when declared in the config.
+	ref string
+	// imports maps the names of the orbs imported by the orb to their key within Orbs.
+	imports map[string]string
 }
 
+// Orbs holds every orb of a compilation. The orbs of the config are keyed by their name, while orbs imported
+// by other orbs are keyed by their reference.
 type Orbs map[string]Orb
 
-func (orbs Orbs) GetExecutorNode(ref string) (RawNode, bool) {
-	before, after, ok := strings.Cut(ref, "/")
-	if !ok {
-		return RawNode{}, false
+// lookup returns the key of the orb and the name within it that ref designates from the scope of orbCtx.
+// Prefixed references such as node/install designate an orb of the config, or within an orb, one of the
+// orbs it imports. Unprefixed references designate the orb of orbCtx itself.
+func (orbs Orbs) lookup(orbCtx, ref string) (key, name string, ok bool) {
+	prefix, name, prefixed := strings.Cut(ref, "/")
+	switch {
+	case !prefixed:
+		key, name = orbCtx, ref
+	case orbCtx == "":
+		key = prefix
+	default:
+		if key, ok = orbs[orbCtx].imports[prefix]; !ok {
+			return "", "", false
+		}
 	}
-	orb, ok := orbs[before]
+	_, ok = orbs[key]
+	return key, name, ok
+}
+
+func (orbs Orbs) GetExecutorNode(orbCtx, ref string) (RawNode, string, bool) {
+	key, name, ok := orbs.lookup(orbCtx, ref)
 	if !ok {
-		return RawNode{}, false
+		return RawNode{}, "", false
 	}
-	node, ok := orb.Executors[after]
-	return node, ok
+	node, ok := orbs[key].Executors[name]
+	return node, key, ok
 }
 
 func (orbs Orbs) GetJobNode(ref string) (RawNode, bool) {
-	before, after, ok := strings.Cut(ref, "/")
-	if !ok {
-		return RawNode{}, false
-	}
-	orb, ok := orbs[before]
+	key, name, ok := orbs.lookup("", ref)
 	if !ok {
 		return RawNode{}, false
 	}
-	node, ok := orb.Jobs[after]
+	node, ok := orbs[key].Jobs[name]
 	return node, ok
 }
 
 func (orbs Orbs) GetCommandNode(orbCtx, ref string) (RawNode, string, bool) {
-	before, after, ok := strings.Cut(ref, "/")
-	if !ok {
-		before = orbCtx
-		after = ref
-	}
-	orb, ok := orbs[before]
+	key, name, ok := orbs.lookup(orbCtx, ref)
 	if !ok {
 		return RawNode{}, "", false
 	}
-	node, ok := orb.Commands[after]
-	return node, before, ok
+	node, ok := orbs[key].Commands[name]
+	return node, key, ok
+}
+
+// PipelineParameters returns the pipeline parameters declared at the root of the source config.
+func PipelineParameters(source []byte) (Parameters, error) {
+	var rootNode RawNode
+	if err := yaml.Unmarshal(source, &rootNode); err != nil {
+		return nil, fmt.Errorf("invalid source: %v", err)
+	}
+	if rootNode.Node == nil {
+		return nil, nil
+	}
+
+	resolveAliases(rootNode.Node)
+
+	return getParametersFromRootNode(rootNode.Node)
 }
 
 func getParametersFromNode(node *yaml.Node) (Parameters, error) {
 	var parameterNode struct {
 		Parameters Parameters `yaml:"parameters"`
 	}
-	if err := node.Decode(&parameterNode); err != nil {
+	if err := decode(node, &parameterNode); err != nil {
 		return nil, err
 	}
 
@@ -545,10 +728,12 @@ func getParametersFromRootNode(node *yaml.Node) (Parameters, error) {
 	var errs []error
 	for name, param := range parameters {
 		if param.Default == nil {
-			errs = append(errs, fmt.Errorf("%s.default is required", name))
+			errs = append(errs, errAtPosition(param.pos, withCode(CodeMissingDefault, fmt.Errorf("%s.default is required", name))))
 		}
 	}
 
+	errs = append(errs, parameters.validateDeclarations("pipeline")...)
+
 	if len(errs) > 0 {
 		return nil, PrettyErr{Errors: errs}
 	}
@@ -556,7 +741,60 @@ func getParametersFromRootNode(node *yaml.Node) (Parameters, error) {
 	return parameters, nil
 }
 
-func validateParameters(parameters map[string]Parameter, values ParamValues) (errs []error) {
+// validateDeclarations validates the parameters declared by the jobs, commands and executors of the config.
+func (c Compiler) validateDeclarations() error {
+	if errs := declarationErrs("", c.root.Jobs, c.root.Commands, c.root.Executors); len(errs) > 0 {
+		return PrettyErr{Message: "parameter declaration error(s):", Errors: errs}
+	}
+	return nil
+}
+
+// declarationErrs validates the parameters declared by jobs, commands and executors. Definitions of an orb
+// are named after the orb's key, such as command tools/install.
+func declarationErrs(orb string, jobs, commands, executors map[string]RawNode) []error {
+	var errs []error
+
+	check := func(kind, scope string, definitions map[string]RawNode) {
+		names := maps.Keys(definitions)
+		slices.Sort(names)
+		for _, name := range names {
+			qualified := scope + " " + name
+			if orb != "" {
+				qualified = scope + " " + orb + "/" + name
+			}
+			parameters, err := getParametersFromNode(definitions[name].Node)
+			if err != nil {
+				errs = append(errs, withContext(qualified, kind+"."+name, err))
+				continue
+			}
+			for _, err := range parameters.validateDeclarations(qualified) {
+				errs = append(errs, withContext("", kind+"."+name+".parameters", err))
+			}
+		}
+	}
+
+	check("jobs", "job", jobs)
+	check("commands", "command", commands)
+	check("executors", "executor", executors)
+
+	return errs
+}
+
+// validateParameters validates values against the parameter declarations. Errors are positioned at the
+// offending value when known, or else at the declaration or the invocation site at.
+func validateParameters(parameters map[string]Parameter, values ParamValues, at Position) (errs []error) {
+	valueErr := func(name string, value ParamValue, parameter Parameter, err error) error {
+		switch {
+		case value.pos.Line != 0:
+			err = errAtPosition(value.pos, err)
+		case parameter.pos.Line != 0:
+			err = errAtPosition(parameter.pos, err)
+		default:
+			err = errAtPosition(at, err)
+		}
+		return withContext("", name, err)
+	}
+
 	var missingArgs []string
 	for name, parameter := range parameters {
 		value, ok := values.Lookup(name)
@@ -570,34 +808,35 @@ func validateParameters(parameters map[string]Parameter, values ParamValues) (er
 		if actualType := value.GetType(); parameter.Type != actualType {
 			switch {
 			case parameter.Type == "enum" && !slices.Contains(parameter.Enum, value.value):
-				errs = append(errs, ParamEnumMismatchErr{
+				errs = append(errs, valueErr(name, value, parameter, ParamEnumMismatchErr{
 					Name:    name,
 					Targets: parameter.Enum,
 					Value:   value.value,
-				})
+				}))
 
 			case parameter.Type == "env_var_name" && actualType == "string":
 				continue
 
 			default:
-				errs = append(errs, ParamTypeMismatchErr{
+				errs = append(errs, valueErr(name, value, parameter, ParamTypeMismatchErr{
 					Name: name,
 					Want: parameter.Type,
 					Got:  actualType,
-				})
+				}))
 
 			}
 		}
 	}
 
-	for name := range values.Values {
+	for name, value := range values.Values {
 		if _, ok := parameters[name]; !ok {
-			errs = append(errs, fmt.Errorf("unknown argument: %s", name))
+			errs = append(errs, valueErr(name, value, Parameter{}, withCode(CodeUnknownArgument, fmt.Errorf("unknown argument: %s", name))))
 		}
 	}
 
 	if len(missingArgs) > 0 {
-		errs = append(errs, MissingParamsErr(missingArgs))
+		slices.Sort(missingArgs)
+		errs = append(errs, errAtPosition(at, MissingParamsErr(missingArgs)))
 	}
 
 	return
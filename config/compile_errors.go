@@ -1,9 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"sort"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/davidmdm/yaml"
 )
 
 type ParamTypeMismatchErr struct {
@@ -16,6 +20,8 @@ func (err ParamTypeMismatchErr) Error() string {
 	return fmt.Sprintf("type mismatch for param %s: wanted %s but got %s", err.Name, err.Want, err.Got)
 }
 
+func (ParamTypeMismatchErr) Code() ErrorCode { return CodeParamTypeMismatch }
+
 type ParamEnumMismatchErr struct {
 	Name    string
 	Targets []any
@@ -23,39 +29,115 @@ type ParamEnumMismatchErr struct {
 }
 
 func (err ParamEnumMismatchErr) Error() string {
-	targets := make([]string, len(err.Targets))
-	for i, elem := range err.Targets {
-		targets[i] = fmt.Sprint(elem)
-	}
-
 	return fmt.Sprintf(
 		"enum mismatch for param %s: wanted one of (%s) but got %v",
-		err.Name, strings.Join(targets, ", "), err.Value,
+		err.Name, joinAny(err.Targets), err.Value,
 	)
 }
 
+func (ParamEnumMismatchErr) Code() ErrorCode { return CodeParamEnumMismatch }
+
 type MissingParamsErr []string
 
 func (err MissingParamsErr) Error() string {
 	return fmt.Sprintf("missing required parameter: %s", strings.Join(err, ", "))
 }
 
+func (MissingParamsErr) Code() ErrorCode { return CodeMissingParams }
+
+// SourceErr is an error located at a position within a source document. File is the name of the
+// config or the reference of the orb the error originates from, and is empty when unknown.
+type SourceErr struct {
+	File string
+	Position
+	Err error
+}
+
+func (err *SourceErr) Error() string {
+	return Diagnose(err).String()
+}
+
+func (err *SourceErr) Unwrap() error {
+	return err.Err
+}
+
+// SourceErrors returns every positioned error within the error tree of err.
+func SourceErrors(err error) []*SourceErr {
+	var result []*SourceErr
+	walkErrors(err, func(err *SourceErr) { result = append(result, err) })
+	return result
+}
+
+// errAt attaches the position of node to err, unless err already carries a position.
+func errAt(node *yaml.Node, err error) error {
+	if node == nil {
+		return err
+	}
+	return errAtPosition(positionOf(node), err)
+}
+
+func errAtPosition(pos Position, err error) error {
+	if err == nil || pos.Line == 0 || errors.As(err, new(*SourceErr)) {
+		return err
+	}
+	return &SourceErr{Position: pos, Err: err}
+}
+
+// setErrFile sets the file of every positioned error within err that does not have a file yet.
+func setErrFile(err error, file string) error {
+	walkErrors(err, func(err *SourceErr) {
+		if err.File == "" {
+			err.File = file
+		}
+	})
+	return err
+}
+
+func walkErrors(err error, fn func(*SourceErr)) {
+	if sourceErr, ok := err.(*SourceErr); ok {
+		fn(sourceErr)
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		walkErrors(e.Unwrap(), fn)
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			walkErrors(err, fn)
+		}
+	}
+}
+
+var yamlLineExpr = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// yamlErr converts the syntax errors of the yaml library, which only report the line within their message,
+// into positioned errors. Errors decoding nodes are positioned by decode instead.
+func yamlErr(err error) error {
+	if err == nil || errors.As(err, new(*SourceErr)) || !yamlLineExpr.MatchString(err.Error()) {
+		return err
+	}
+	return withCode(CodeSyntax, yamlLineErr(err.Error()))
+}
+
+func yamlLineErr(msg string) error {
+	match := yamlLineExpr.FindStringSubmatch(msg)
+	if match == nil {
+		return errors.New(msg)
+	}
+	line, _ := strconv.Atoi(match[1])
+	return &SourceErr{Position: Position{Line: line}, Err: errors.New(match[2])}
+}
+
 type OrderedErr struct {
 	Message string
 	Errors  []error
 }
 
 func (err OrderedErr) Error() string {
-	switch len(err.Errors) {
-	case 0:
-		return err.Message
-	default:
-		indentedErrors := make([]string, len(err.Errors))
-		for i, e := range err.Errors {
-			indentedErrors[i] = indent("- " + e.Error())
-		}
-		return err.Message + "\n" + strings.Join(indentedErrors, "\n")
-	}
+	return Diagnose(err).String()
+}
+
+func (err OrderedErr) Unwrap() []error {
+	return err.Errors
 }
 
 type PrettyErr struct {
@@ -63,9 +145,50 @@ type PrettyErr struct {
 	Errors  []error
 }
 
+// Error renders the errors sorted, as they are usually gathered in no particular order.
 func (err PrettyErr) Error() string {
-	sort.SliceStable(err.Errors, func(i, j int) bool { return err.Errors[i].Error() < err.Errors[j].Error() })
-	return OrderedErr(err).Error()
+	return Diagnose(err).String()
+}
+
+func (err PrettyErr) Unwrap() []error {
+	return err.Errors
+}
+
+// naturalLess compares strings such that runs of digits are ordered by their numeric value,
+// so that errors positioned on line 9 sort before those on line 10.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			numA, restA := cutDigits(a)
+			numB, restB := cutDigits(b)
+			if len(numA) != len(numB) {
+				return len(numA) < len(numB)
+			}
+			if numA != numB {
+				return numA < numB
+			}
+			a, b = restA, restB
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func cutDigits(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	digits = strings.TrimLeft(s[:i], "0")
+	return digits, s[i:]
 }
 
 func indent(value string) string {
@@ -134,3 +134,108 @@ func TestConfigs(t *testing.T) {
 		}
 	})
 }
+
+func TestSourceErrors(t *testing.T) {
+	source := []byte(`version: 2.1
+
+orbs:
+  util: acme/util@1.0.0
+
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - util/greet:
+          greeting: 42
+      - util/broken
+
+workflows:
+  main:
+    jobs:
+      - test
+`)
+
+	orb := `commands:
+  greet:
+    parameters:
+      greeting:
+        type: string
+    steps:
+      - run: echo << parameters.greeting >>
+  broken:
+    steps:
+      - missing
+`
+
+	compiler := config.Compiler{
+		Filename:     ".circleci/config.yml",
+		GetOrbSource: func(ref string) (string, error) { return orb, nil },
+	}
+
+	_, err := compiler.Compile(source, nil)
+	require.Error(t, err)
+
+	type location struct {
+		File   string
+		Line   int
+		Column int
+	}
+
+	var locations []location
+	for _, err := range config.SourceErrors(err) {
+		locations = append(locations, location{err.File, err.Line, err.Column})
+	}
+
+	require.ElementsMatch(
+		t,
+		[]location{
+			{File: ".circleci/config.yml", Line: 12, Column: 21},
+			{File: "acme/util@1.0.0", Line: 10, Column: 9},
+		},
+		locations,
+	)
+
+	t.Run("decode errors", func(t *testing.T) {
+		source := []byte(`version: 2.1
+jobs:
+  test:
+    docker:
+      - image: go
+    parallelism: abc
+    steps:
+      - checkout
+workflows:
+  main:
+    jobs:
+      - test
+`)
+
+		_, err := config.Compiler{Filename: "c.yml"}.Compile(source, nil)
+		require.Len(t, config.SourceErrors(err), 1)
+
+		sourceErr := config.SourceErrors(err)[0]
+		require.Equal(t, config.Position{Line: 6, Column: 18}, sourceErr.Position)
+		require.EqualError(t, sourceErr, "c.yml:6:18: cannot unmarshal !!str `abc` into int")
+	})
+}
+
+// runCommands returns the commands of the run steps of a job within a compiled config.
+func runCommands(t *testing.T, compiled []byte, job string) []string {
+	t.Helper()
+
+	var result struct {
+		Jobs map[string]struct {
+			Steps []struct {
+				Run struct{ Command string } `yaml:"run"`
+			} `yaml:"steps"`
+		} `yaml:"jobs"`
+	}
+	require.NoError(t, yaml.Unmarshal(compiled, &result))
+
+	var commands []string
+	for _, step := range result.Jobs[job].Steps {
+		commands = append(commands, step.Run.Command)
+	}
+	return commands
+}
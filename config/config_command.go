@@ -24,6 +24,8 @@ type Run struct {
 	WorkDir         string      `yaml:"working_directory,omitempty"`
 	NoOutputTimeout string      `yaml:"no_output_timeout,omitempty"`
 	When            RunWhen     `yaml:"when,omitempty"`
+	MaxAutoReruns   int         `yaml:"max_auto_reruns,omitempty"`
+	AutoRerunDelay  string      `yaml:"auto_rerun_delay,omitempty"`
 }
 
 func (cmd Run) Validate() error {
@@ -44,12 +46,13 @@ func (when *RunWhen) UnmarshalYAML(node *yaml.Node) error {
 	case "always", "on_success", "on_fail":
 		return nil
 	default:
-		return fmt.Errorf("invalid when attribute: wanted one of always, on_success, or on_fail but got: %s", *when)
+		return errAt(node, withCode(CodeInvalidStep, fmt.Errorf("invalid when attribute: wanted one of always, on_success, or on_fail but got: %s", *when)))
 	}
 }
 
 type Checkout struct {
-	Path string `yaml:"path,omitempty"`
+	Path   string `yaml:"path,omitempty"`
+	Method string `yaml:"method,omitempty"`
 }
 
 type SetupRemoteDocker struct {
@@ -72,6 +75,8 @@ func (cmd SaveCache) Validate() error {
 	}
 	if cmd.Key == "" {
 		errs = append(errs, errors.New("save_cache.key is required"))
+	} else if _, err := ParseCacheKey(cmd.Key); err != nil {
+		errs = append(errs, err)
 	}
 
 	switch len(errs) {
@@ -97,7 +102,28 @@ func (cmd RestoreCache) Validate() error {
 	if cmd.Key == "" && len(cmd.Keys) == 0 {
 		return fmt.Errorf("restore_cache: requires one of key or keys to be present")
 	}
-	return nil
+
+	var errs []error
+	for _, key := range append([]string{cmd.Key}, cmd.Keys...) {
+		if key == "" {
+			continue
+		}
+		if _, err := ParseCacheKey(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return PrettyErr{
+			Message: "errors within restore_cache command:",
+			Errors:  errs,
+		}
+	}
 }
 
 type StoreArtifacts struct {
@@ -166,6 +192,7 @@ func (cmd AttachWorkspace) Validate() error {
 
 type AddSSHKeys struct {
 	Fingerprints []string `yaml:"fingerprints"`
+	Name         string   `yaml:"name,omitempty"`
 }
 
 type StepCMD struct {
@@ -213,16 +240,24 @@ type Step struct {
 	Type    string      `yaml:"-"`
 	Params  ParamValues `yaml:"-"`
 	StepCMD `yaml:",inline"`
+
+	pos Position
+	// file is the orb reference the step was declared in, or empty for the config itself.
+	file string
+	// orb is the name of the orb whose job declared the step, whose commands it references without a prefix.
+	orb string
 }
 
 var stepCmds = topLevelKeys(reflect.TypeOf(StepCMD{}))
 
 func (step *Step) UnmarshalYAML(node *yaml.Node) (err error) {
+	step.pos = positionOf(node)
+
 	defer func() {
-		if err != nil {
-			return
+		if err == nil {
+			err = step.StepCMD.Validate(step.Type)
 		}
-		err = step.StepCMD.Validate(step.Type)
+		err = errAt(node, withCode(CodeInvalidStep, err))
 	}()
 
 	if err := node.Decode(&step.Type); err == nil {
@@ -277,7 +312,7 @@ type Steps List[Step]
 
 func (steps *Steps) UnmarshalYAML(node *yaml.Node) error {
 	if err := node.Decode((*List[Step])(steps)); err != nil {
-		return fmt.Errorf("invalid step(s): %w", err)
+		return withContext("invalid step(s)", "steps", err)
 	}
 	return nil
 }
@@ -38,7 +38,7 @@ func (expr *Expression) UnmarshalYAML(node *yaml.Node) error {
 		return err
 	}
 	if len(raw) == 0 {
-		return errors.New("pattern cannot be empty")
+		return errAt(node, withCode(CodeInvalidCondition, errors.New("pattern cannot be empty")))
 	}
 	if raw[0] == '/' && raw[len(raw)-1] == '/' {
 		raw = raw[1 : len(raw)-1]
@@ -46,7 +46,7 @@ func (expr *Expression) UnmarshalYAML(node *yaml.Node) error {
 
 	expression, err := regexp.Compile(raw)
 	if err != nil {
-		return subexpressionErr(fmt.Sprintf("failed to compile pattern: %v", err))
+		return errAt(node, withCode(CodeInvalidCondition, subexpressionErr(fmt.Sprintf("failed to compile pattern: %v", err))))
 	}
 
 	*expr = Expression(*expression)
@@ -95,7 +95,7 @@ func (cond *Condition) UnmarshalYAML(node *yaml.Node) error {
 	}
 
 	if initializedFields > 1 {
-		return errors.New("only one of [and, or, equal, not, matches] can be defined")
+		return errAt(node, withCode(CodeInvalidCondition, errors.New("only one of [and, or, equal, not, matches] can be defined")))
 	}
 
 	return node.Decode(&cond.Literal)
@@ -27,7 +27,7 @@ func (env *Environment) UnmarshalYAML(node *yaml.Node) error {
 		for _, s := range stringSlice {
 			key, value, ok := strings.Cut(s, "=")
 			if !ok {
-				return fmt.Errorf("environment string should be of form KEY=value, not %s", s)
+				return errAt(node, withCode(CodeInvalidEnvironment, fmt.Errorf("environment string should be of form KEY=value, not %s", s)))
 			}
 			(*env)[key] = value
 		}
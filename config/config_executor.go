@@ -56,7 +56,7 @@ func (version *XCodeVersion) UnmarshalYAML(node *yaml.Node) error {
 		return err
 	}
 	if !xCodeVersionExpression.MatchString(string(*version)) {
-		return fmt.Errorf("xcode version %q does not satisfy regexp: %v", *version, xCodeVersionExpression)
+		return errAt(node, withCode(CodeInvalidExecutor, fmt.Errorf("xcode version %q does not satisfy regexp: %v", *version, xCodeVersionExpression)))
 	}
 	return nil
 }
@@ -11,10 +11,11 @@ type Job struct {
 	WorkingDirectory string     `yaml:"working_directory,omitempty"`
 	Shell            StringList `yaml:"shell,omitempty"`
 
-	Environment Environment          `yaml:"environment,omitempty"`
-	Parallelism int                  `yaml:"parallelism,omitempty"`
-	Parameters  map[string]Parameter `yaml:"parameters,omitempty"`
-	Steps       Steps                `yaml:"steps"`
+	Environment      Environment          `yaml:"environment,omitempty"`
+	Parallelism      int                  `yaml:"parallelism,omitempty"`
+	CircleCIIPRanges bool                 `yaml:"circleci_ip_ranges,omitempty"`
+	Parameters       map[string]Parameter `yaml:"parameters,omitempty"`
+	Steps            Steps                `yaml:"steps"`
 
 	Executor       JobExecutor `yaml:"executor,omitempty"`
 	InlineExecutor `yaml:",inline"`
@@ -28,9 +29,13 @@ type InlineExecutor Executor
 type JobExecutor struct {
 	Name        string
 	ParamValues ParamValues
+
+	pos Position
 }
 
 func (executor *JobExecutor) UnmarshalYAML(node *yaml.Node) error {
+	executor.pos = positionOf(node)
+
 	if err := node.Decode(&executor.Name); err == nil {
 		return nil
 	}
@@ -42,7 +47,7 @@ func (executor *JobExecutor) UnmarshalYAML(node *yaml.Node) error {
 
 	name := executor.ParamValues.Values["name"].String
 	if name == "" {
-		return errors.New("invalid job executor: name required")
+		return errAt(node, withCode(CodeInvalidExecutor, errors.New("invalid job executor: name required")))
 	}
 
 	executor.Name = name
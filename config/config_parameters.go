@@ -1,12 +1,12 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 
-	"github.com/davidmdm/handlebars"
 	"github.com/davidmdm/yaml"
+	"golang.org/x/exp/slices"
 )
 
 type Parameter struct {
@@ -14,6 +14,17 @@ type Parameter struct {
 	Type        string `yaml:"type"`
 	Default     any    `yaml:"default,omitempty"`
 	Enum        []any  `yaml:"enum,omitempty"`
+
+	pos Position
+}
+
+func (param *Parameter) UnmarshalYAML(node *yaml.Node) error {
+	type parameter Parameter
+	if err := node.Decode((*parameter)(param)); err != nil {
+		return err
+	}
+	param.pos = positionOf(node)
+	return nil
 }
 
 type Parameters map[string]Parameter
@@ -30,6 +41,95 @@ func (params Parameters) JoinDefaults(values map[string]any) map[string]any {
 	return result
 }
 
+// parameterTypes are the types a parameter can be declared with.
+var parameterTypes = []string{"string", "boolean", "integer", "enum", "executor", "steps", "env_var_name"}
+
+// validateDeclarations validates the parameters declared by scope, such as "job test", independently of any
+// argument: their type must be known, enums must not be empty, and defaults must match their type.
+func (params Parameters) validateDeclarations(scope string) []error {
+	var errs []error
+
+	declErr := func(name string, param Parameter, format string, args ...any) {
+		err := withCode(CodeInvalidParamDecl, fmt.Errorf("parameter %s of %s: "+format, append([]any{name, scope}, args...)...))
+		errs = append(errs, withContext("", name, errAtPosition(param.pos, err)))
+	}
+
+	for name, param := range params {
+		if !slices.Contains(parameterTypes, param.Type) {
+			if suggestion := suggest(param.Type, parameterTypes); suggestion != "" {
+				declErr(name, param, "unknown type %q, did you mean %s?", param.Type, suggestion)
+			} else {
+				declErr(name, param, "unknown type %q: expected one of %s", param.Type, strings.Join(parameterTypes, ", "))
+			}
+			continue
+		}
+
+		if param.Type == "enum" && len(param.Enum) == 0 {
+			declErr(name, param, "enum must declare at least one value")
+			continue
+		}
+
+		if param.Default == nil {
+			continue
+		}
+
+		if param.Type == "enum" {
+			if !slices.Contains(param.Enum, param.Default) {
+				declErr(name, param, "default %v is not one of (%s)", param.Default, joinAny(param.Enum))
+			}
+			continue
+		}
+
+		if got := defaultType(param.Default); !defaultMatches(param.Type, got) {
+			declErr(name, param, "default must be of type %s but got %s", param.Type, got)
+		}
+	}
+
+	return errs
+}
+
+// defaultType returns the type of a default value as named by parameter types.
+func defaultType(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int:
+		return "integer"
+	case []any:
+		return "steps"
+	case map[string]any:
+		return "executor"
+	case float64:
+		// Numbers that are not integers, such as 3.8, can only be used as strings.
+		return "string"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func defaultMatches(paramType, got string) bool {
+	switch paramType {
+	case "string":
+		// As with CircleCI, unquoted scalars such as 18 or true are taken as strings.
+		return got == "string" || got == "integer" || got == "boolean"
+	case "env_var_name":
+		return got == "string"
+	case "executor":
+		// Executors are referenced by name or declared inline.
+		return got == "string" || got == "executor"
+	}
+	return paramType == got
+}
+
+func joinAny(values []any) string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = fmt.Sprint(value)
+	}
+	return strings.Join(result, ", ")
+}
+
 type ParamValues struct {
 	Values map[string]ParamValue
 	parent reflect.Type
@@ -46,15 +146,7 @@ func toParamValues(m map[string]any) ParamValues {
 func (params ParamValues) AsMap() map[string]any {
 	result := make(map[string]any, len(params.Values))
 	for k, v := range params.Values {
-		if t := v.GetType(); t == "executor" || t == "steps" {
-			raw, _ := yaml.Marshal(v.value)
-			var x any
-			yaml.Unmarshal(raw, &x)
-			raw, _ = json.Marshal(x)
-			result[k] = handlebars.SafeString(raw)
-		} else {
-			result[k] = v.value
-		}
+		result[k] = v.value
 	}
 	return result
 }
@@ -75,21 +167,26 @@ func (param *ParamValues) UnmarshalYAML(node *yaml.Node) error {
 		return node.Decode(&param.Values)
 	}
 
-	var intermediate map[string]any
-	if err := node.Decode(&intermediate); err != nil {
-		return err
+	if node.Kind != yaml.MappingNode {
+		return errAt(node, withCode(CodeInvalidParamValue, fmt.Errorf("expected a map but got: %s", node.Tag)))
 	}
 
-	for _, key := range topLevelKeys(param.parent) {
-		delete(intermediate, key)
-	}
+	keys := topLevelKeys(param.parent)
 
-	data, err := yaml.Marshal(intermediate)
-	if err != nil {
-		return err
+	param.Values = map[string]ParamValue{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if slices.Contains(keys, key) {
+			continue
+		}
+		var value ParamValue
+		if err := node.Content[i+1].Decode(&value); err != nil {
+			return err
+		}
+		param.Values[key] = value
 	}
 
-	return yaml.Unmarshal(data, &param.Values)
+	return nil
 }
 
 type ParamValue struct {
@@ -100,6 +197,7 @@ type ParamValue struct {
 	Executor JobExecutor
 
 	value any
+	pos   Position
 }
 
 func (param ParamValue) GetType() string {
@@ -120,6 +218,7 @@ func (param ParamValue) GetType() string {
 }
 
 func (param *ParamValue) UnmarshalYAML(node *yaml.Node) error {
+	param.pos = positionOf(node)
 	if err := node.Decode(&param.Integer); err == nil {
 		param.value = param.Integer
 		return nil
@@ -144,7 +243,7 @@ func (param *ParamValue) UnmarshalYAML(node *yaml.Node) error {
 	var v any
 	_ = node.Decode(&v)
 
-	return fmt.Errorf("invalid param value: %v", v)
+	return errAt(node, withCode(CodeInvalidParamValue, fmt.Errorf("invalid param value: %v", v)))
 }
 
 func (param ParamValue) MarshalYAML() (any, error) {
@@ -34,3 +34,46 @@ func TestParamValues(t *testing.T) {
 
 	require.Equal(t, []string{"age", "nick_name"}, keys)
 }
+
+func TestParameterDeclarations(t *testing.T) {
+	var params Parameters
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name:
+  type: string
+  default: world
+port:
+  type: integer
+  default: "8080"
+image:
+  type: executor
+  default: go
+tool:
+  type: strng
+level:
+  type: enum
+  enum: [info, debug]
+  default: debug
+python:
+  type: string
+  default: 3.8
+node:
+  type: string
+  default: 18
+retries:
+  type: integer
+  default: 2.5
+`), &params))
+
+	errs := params.validateDeclarations("job test")
+	require.Len(t, errs, 3)
+
+	slices.SortFunc(errs, func(a, b error) bool { return naturalLess(a.Error(), b.Error()) })
+
+	require.EqualError(t, errs[0], "6:3: parameter port of job test: default must be of type integer but got string")
+	require.EqualError(t, errs[1], `12:3: parameter tool of job test: unknown type "strng", did you mean string?`)
+	require.EqualError(t, errs[2], "24:3: parameter retries of job test: default must be of type integer but got string")
+
+	diagnostic := Diagnose(errs[0])
+	require.Equal(t, CodeInvalidParamDecl, diagnostic.Code)
+	require.Equal(t, "port", diagnostic.Path)
+}
@@ -1,38 +1,44 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/davidmdm/yaml"
+	"golang.org/x/exp/slices"
 )
 
 type Workflows map[string]Workflow
 
 type Workflow struct {
-	Jobs []WorkflowJob `yaml:"jobs"`
-	When *Condition    `yaml:"when,omitempty"`
+	Triggers []Trigger     `yaml:"triggers,omitempty"`
+	Jobs     []WorkflowJob `yaml:"jobs"`
+	When     *Condition    `yaml:"when,omitempty"`
 	// TODO Version ... // hard coded version: 2 - it looks like
 }
 
 func (workflow *Workflow) UnmarshalYAML(node *yaml.Node) error {
 	var state struct {
-		Jobs   []WorkflowJob `yaml:"jobs"`
-		Unless *Condition    `yaml:"unless"`
-		When   *Condition    `yaml:"when"`
+		Triggers []Trigger     `yaml:"triggers"`
+		Jobs     []WorkflowJob `yaml:"jobs"`
+		Unless   *Condition    `yaml:"unless"`
+		When     *Condition    `yaml:"when"`
 	}
 	if err := node.Decode(&state); err != nil {
 		return err
 	}
 
 	if len(state.Jobs) == 0 {
-		return fmt.Errorf("workflow must contain at least one job")
+		return errAt(node, withCode(CodeInvalidWorkflow, errors.New("workflow must contain at least one job")))
 	}
 
 	workflow.Jobs = state.Jobs
+	workflow.Triggers = state.Triggers
 
 	if state.Unless != nil && state.When != nil {
-		return fmt.Errorf("cannot declare both when and unless at the same time")
+		return errAt(node, withCode(CodeInvalidWorkflow, errors.New("cannot declare both when and unless at the same time")))
 	}
 
 	if state.Unless != nil {
@@ -48,17 +54,118 @@ func (workflow *Workflow) UnmarshalYAML(node *yaml.Node) error {
 type JobMatrix struct {
 	Parameters map[string][]any `yaml:"parameters"`
 	Exclude    []map[string]any `yaml:"exclude,omitempty"`
+	// Alias is the name by which other jobs require every job of the matrix. Defaults to the name of the
+	// workflow job.
+	Alias string `yaml:"alias,omitempty"`
+
+	// excludePos holds the position of each exclude entry.
+	excludePos []Position
+}
+
+func (matrix *JobMatrix) UnmarshalYAML(node *yaml.Node) error {
+	type jobMatrix JobMatrix
+	if err := node.Decode((*jobMatrix)(matrix)); err != nil {
+		return err
+	}
+
+	var state struct {
+		Exclude []RawNode `yaml:"exclude"`
+	}
+	if err := node.Decode(&state); err != nil {
+		return err
+	}
+	for _, exclude := range state.Exclude {
+		matrix.excludePos = append(matrix.excludePos, positionOf(exclude.Node))
+	}
+
+	return nil
 }
 
 type WorkflowJobProps struct {
-	Name      string     `yaml:"name,omitempty"`
-	Type      string     `yaml:"type,omitempty"`
-	Requires  StringList `yaml:"requires,omitempty"`
-	Context   StringList `yaml:"context,omitempty"`
-	Filters   Filters    `yaml:"filters,omitempty"`
-	Matrix    JobMatrix  `yaml:"matrix,omitempty"`
-	PreSteps  []Step     `yaml:"pre-steps,omitempty"`
-	PostSteps []Step     `yaml:"post-steps,omitempty"`
+	Name      string       `yaml:"name,omitempty"`
+	Type      string       `yaml:"type,omitempty"`
+	Requires  Requirements `yaml:"requires,omitempty"`
+	Context   StringList   `yaml:"context,omitempty"`
+	Filters   Filters      `yaml:"filters,omitempty"`
+	Matrix    JobMatrix    `yaml:"matrix,omitempty"`
+	PreSteps  []Step       `yaml:"pre-steps,omitempty"`
+	PostSteps []Step       `yaml:"post-steps,omitempty"`
+}
+
+// Requirement is an entry of the requires of a workflow job: the name of the required job, and the statuses of
+// that job which let the requiring job run. Without statuses, the required job must succeed.
+type Requirement struct {
+	Job    string
+	Status StringList
+}
+
+// requirementStatuses are the statuses a requirement may wait for.
+var requirementStatuses = []string{"success", "failed", "canceled"}
+
+// Requirements are the requires of a workflow job. Each entry is either the name of a job, or a map of job
+// names to the status, or list of statuses, they must end with, such as `build: failed`.
+type Requirements []Requirement
+
+// Jobs returns the names of the required jobs.
+func (requires Requirements) Jobs() []string {
+	jobs := make([]string, len(requires))
+	for i, requirement := range requires {
+		jobs[i] = requirement.Job
+	}
+	return jobs
+}
+
+func (requires *Requirements) UnmarshalYAML(node *yaml.Node) error {
+	entries := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		entries = node.Content
+	}
+
+	var result Requirements
+	for _, entry := range entries {
+		if entry.Kind != yaml.MappingNode {
+			var job string
+			if err := entry.Decode(&job); err != nil {
+				return err
+			}
+			result = append(result, Requirement{Job: job})
+			continue
+		}
+
+		for i := 0; i+1 < len(entry.Content); i += 2 {
+			requirement := Requirement{Job: entry.Content[i].Value}
+			if err := entry.Content[i+1].Decode(&requirement.Status); err != nil {
+				return err
+			}
+			for _, status := range requirement.Status {
+				if !slices.Contains(requirementStatuses, status) {
+					err := fmt.Errorf("invalid status %s required of job %s: expected one of %s", status, requirement.Job, strings.Join(requirementStatuses, ", "))
+					return errAt(entry.Content[i+1], withCode(CodeInvalidRequirement, err))
+				}
+			}
+			result = append(result, requirement)
+		}
+	}
+
+	*requires = result
+	return nil
+}
+
+func (requires Requirements) MarshalYAML() (any, error) {
+	entries := make([]any, len(requires))
+	for i, requirement := range requires {
+		if len(requirement.Status) == 0 {
+			entries[i] = requirement.Job
+			continue
+		}
+		entries[i] = map[string]StringList{requirement.Job: requirement.Status}
+	}
+	if len(entries) == 1 {
+		if job, ok := entries[0].(string); ok {
+			return job, nil
+		}
+	}
+	return entries, nil
 }
 
 type WorkflowJobData struct {
@@ -81,6 +188,8 @@ func (wfjd WorkflowJobData) MarshalYAML() (any, error) {
 type WorkflowJob struct {
 	Key             string `yaml:"-"`
 	WorkflowJobData `yaml:",inline"`
+
+	pos Position
 }
 
 func (job WorkflowJob) Name() string {
@@ -91,6 +200,8 @@ func (job WorkflowJob) Name() string {
 }
 
 func (job *WorkflowJob) UnmarshalYAML(node *yaml.Node) error {
+	job.pos = positionOf(node)
+
 	if err := node.Decode(&job.Key); err == nil {
 		return nil
 	}
@@ -100,7 +211,7 @@ func (job *WorkflowJob) UnmarshalYAML(node *yaml.Node) error {
 		return err
 	}
 	if len(elem) != 1 {
-		return fmt.Errorf("expected single key in workflow job definition but got: %d", len(elem))
+		return errAt(node, withCode(CodeInvalidWorkflow, fmt.Errorf("expected single key in workflow job definition but got: %d", len(elem))))
 	}
 
 	for key, data := range elem {
@@ -127,3 +238,47 @@ type FilterConditions struct {
 	Only   StringList `yaml:"only,omitempty"`
 	Ignore StringList `yaml:"ignore,omitempty"`
 }
+
+// Trigger is a legacy workflow trigger. Schedules are the only kind of trigger.
+type Trigger struct {
+	Schedule Schedule `yaml:"schedule"`
+}
+
+func (trigger *Trigger) UnmarshalYAML(node *yaml.Node) error {
+	var state struct {
+		Schedule *Schedule `yaml:"schedule"`
+	}
+	if err := node.Decode(&state); err != nil {
+		return err
+	}
+	if state.Schedule == nil {
+		return errAt(node, withCode(CodeInvalidTrigger, errors.New("trigger must declare a schedule")))
+	}
+	trigger.Schedule = *state.Schedule
+	return nil
+}
+
+type Schedule struct {
+	Cron    string  `yaml:"cron"`
+	Filters Filters `yaml:"filters"`
+}
+
+func (schedule *Schedule) UnmarshalYAML(node *yaml.Node) error {
+	type scheduleProps Schedule
+	if err := node.Decode((*scheduleProps)(schedule)); err != nil {
+		return err
+	}
+
+	if schedule.Cron == "" {
+		return errAt(node, withCode(CodeInvalidTrigger, errors.New("schedule must declare a cron expression")))
+	}
+	if _, err := ParseCron(schedule.Cron); err != nil {
+		return errAt(node, withCode(CodeInvalidTrigger, err))
+	}
+
+	if branches := schedule.Filters.Branches; len(branches.Only) == 0 && len(branches.Ignore) == 0 {
+		return errAt(node, withCode(CodeInvalidTrigger, errors.New("schedule must declare a branches filter")))
+	}
+
+	return nil
+}
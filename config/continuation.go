@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/davidmdm/yaml"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// DynamicConfig is the result of compiling a setup config along with its continuation.
+type DynamicConfig struct {
+	// Setup is the compiled setup config.
+	Setup []byte
+	// Continuation is the compiled continuation config.
+	Continuation []byte
+}
+
+// CompileContinuation compiles the setup config for the pipeline values, then the continuation config as the
+// continuation API would receive it from the setup workflow, with the given continuation parameters. The
+// continuation is compiled for the same pipeline values, except for its parameters which are only those
+// passed by the setup workflow. As with CircleCI, the setup config must be a setup config, the continuation
+// may not be one, and parameters passed to the setup pipeline may not be passed again to the continuation.
+func (c Compiler) CompileContinuation(setup []byte, pipelineParams map[string]any, continuation []byte, continuationParams map[string]any) (DynamicConfig, error) {
+	if isSetup, node, err := setupFlag(setup); err != nil {
+		return DynamicConfig{}, setErrFile(withContext("invalid source", "", err), c.Filename)
+	} else if !isSetup {
+		err := errAt(node, withCode(CodeInvalidContinuation, errors.New("config is not a setup config: setup must be true")))
+		return DynamicConfig{}, setErrFile(err, c.Filename)
+	}
+
+	compiledSetup, err := c.Compile(setup, pipelineParams)
+	if err != nil {
+		return DynamicConfig{}, err
+	}
+
+	continuationCompiler := c
+	continuationCompiler.Filename = c.ContinuationFilename
+
+	if isSetup, node, err := setupFlag(continuation); err != nil {
+		return DynamicConfig{}, setErrFile(withContext("invalid source", "", err), c.ContinuationFilename)
+	} else if isSetup {
+		err := errAt(node, withCode(CodeInvalidContinuation, errors.New("a continuation config cannot be a setup config")))
+		return DynamicConfig{}, setErrFile(err, c.ContinuationFilename)
+	}
+
+	setupParams, _ := pipelineParams["parameters"].(map[string]any)
+
+	var collisions []string
+	for name := range continuationParams {
+		if _, ok := setupParams[name]; ok {
+			collisions = append(collisions, name)
+		}
+	}
+	if len(collisions) > 0 {
+		slices.Sort(collisions)
+		var errs []error
+		for _, name := range collisions {
+			errs = append(errs, withContext("", name, withCode(
+				CodeInvalidContinuation,
+				fmt.Errorf("parameter %s was already passed to the setup pipeline", name),
+			)))
+		}
+		return DynamicConfig{}, withContext("", "parameters", PrettyErr{Message: "continuation parameter error(s):", Errors: errs})
+	}
+
+	values := maps.Clone(pipelineParams)
+	if values == nil {
+		values = map[string]any{}
+	}
+	values["parameters"] = continuationParams
+	if continuationParams == nil {
+		values["parameters"] = map[string]any{}
+	}
+
+	compiledContinuation, err := continuationCompiler.Compile(continuation, values)
+	if err != nil {
+		return DynamicConfig{}, err
+	}
+
+	return DynamicConfig{Setup: compiledSetup, Continuation: compiledContinuation}, nil
+}
+
+// setupFlag returns whether the source is a setup config, along with the node of its setup key, or of the
+// document when it has none.
+func setupFlag(source []byte) (bool, *yaml.Node, error) {
+	var root RawNode
+	if err := yaml.Unmarshal(source, &root); err != nil {
+		return false, nil, yamlErr(err)
+	}
+	if root.Node == nil {
+		return false, nil, errAtPosition(Position{Line: 1, Column: 1}, withCode(CodeEmptyConfig, errors.New("config is empty")))
+	}
+
+	var doc struct {
+		Setup RawNode `yaml:"setup"`
+	}
+	if err := root.Decode(&doc); err != nil || doc.Setup.Node == nil {
+		return false, root.Node, nil
+	}
+
+	var setup bool
+	if err := doc.Setup.Decode(&setup); err != nil {
+		return false, doc.Setup.Node, errAt(doc.Setup.Node, withCode(CodeDecode, errors.New("setup must be a boolean")))
+	}
+
+	return setup, doc.Setup.Node, nil
+}
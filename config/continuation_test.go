@@ -0,0 +1,86 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/davidmdm/yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileContinuation(t *testing.T) {
+	setup := []byte(`version: 2.1
+setup: true
+
+parameters:
+  force:
+    type: boolean
+    default: false
+
+jobs:
+  generate:
+    docker:
+      - image: go
+    steps:
+      - run: ./generate << pipeline.parameters.force >>
+
+workflows:
+  setup:
+    jobs:
+      - generate
+`)
+
+	continuation := []byte(`version: 2.1
+
+parameters:
+  service:
+    type: string
+    default: all
+
+jobs:
+  deploy:
+    docker:
+      - image: go
+    steps:
+      - run: deploy << pipeline.parameters.service >> from << pipeline.git.branch >>
+
+workflows:
+  deploy:
+    jobs:
+      - deploy
+`)
+
+	pipelineParams := map[string]any{
+		"git":        map[string]any{"branch": "main"},
+		"parameters": map[string]any{"force": true},
+	}
+
+	result, err := config.Compiler{}.CompileContinuation(setup, pipelineParams, continuation, map[string]any{"service": "api"})
+	require.NoError(t, err)
+
+	var compiledSetup map[string]any
+	require.NoError(t, yaml.Unmarshal(result.Setup, &compiledSetup))
+	require.Equal(t, true, compiledSetup["setup"])
+
+	var compiled map[string]any
+	require.NoError(t, yaml.Unmarshal(result.Continuation, &compiled))
+	require.NotEqual(t, true, compiled["setup"])
+	require.Equal(t, "deploy api from main", runCommands(t, result.Continuation, "deploy")[0])
+
+	t.Run("errors", func(t *testing.T) {
+		compiler := config.Compiler{Filename: "setup.yml", ContinuationFilename: "continuation.yml"}
+
+		_, err := compiler.CompileContinuation(continuation, nil, continuation, nil)
+		require.EqualError(t, err, "setup.yml:1:1: config is not a setup config: setup must be true")
+		require.Equal(t, config.CodeInvalidContinuation, config.Diagnose(err).Code)
+
+		_, err = compiler.CompileContinuation(setup, nil, setup, nil)
+		require.EqualError(t, err, "continuation.yml:2:8: a continuation config cannot be a setup config")
+
+		_, err = compiler.CompileContinuation(setup, pipelineParams, continuation, map[string]any{"service": "api", "force": true})
+		require.EqualError(t, err, "continuation parameter error(s):\n  - parameter force was already passed to the setup pipeline")
+
+		_, err = compiler.CompileContinuation(setup, nil, continuation, map[string]any{"service": "api", "region": "us"})
+		require.EqualError(t, err, "pipeline parameter error(s):\n  - continuation.yml:1:1: unknown argument: region")
+	})
+}
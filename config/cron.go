@@ -0,0 +1,203 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// Cron is a parsed cron expression, as used by scheduled workflow triggers: five fields for the minute, hour,
+// day of the month, month and day of the week, evaluated in UTC. Fields accept *, values, ranges such as 1-5,
+// steps such as */15 or 0-30/10, and lists of those separated by commas. Months and days of the week may be
+// given by their three letter names.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+
+	// domStar and dowStar record whether the day of the month and day of the week were unrestricted. When both
+	// are restricted, a day matches if it matches either of them.
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = [5]cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// ParseCron parses a five field cron expression.
+func ParseCron(expr string) (Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Cron{}, fmt.Errorf("invalid cron expression %q: expected 5 fields but got %d", expr, len(fields))
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		value, err := cronFields[i].parse(field)
+		if err != nil {
+			return Cron{}, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		bits[i] = value
+	}
+
+	// Sunday may be given as either 0 or 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return Cron{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func (field cronField) parse(expr string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+
+		start, end := field.min, field.max
+		if field.name == "day of week" {
+			end = 6
+		}
+
+		if rangeExpr != "*" {
+			lo, hi, isRange := strings.Cut(rangeExpr, "-")
+
+			var err error
+			if start, err = field.value(lo); err != nil {
+				return 0, err
+			}
+			switch {
+			case isRange:
+				if end, err = field.value(hi); err != nil {
+					return 0, err
+				}
+			case !hasStep:
+				end = start
+			}
+			if end < start {
+				return 0, fmt.Errorf("invalid %s range %s: start is greater than end", field.name, rangeExpr)
+			}
+		}
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepExpr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid %s step %q", field.name, stepExpr)
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+func (field cronField) value(expr string) (int, error) {
+	for i, name := range field.names {
+		if strings.EqualFold(expr, name) {
+			return i + field.min, nil
+		}
+	}
+
+	value, err := strconv.Atoi(expr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q", field.name, expr)
+	}
+	if value < field.min || value > field.max {
+		return 0, fmt.Errorf("%s value %d is out of range [%d, %d]", field.name, value, field.min, field.max)
+	}
+	return value, nil
+}
+
+// Next returns the first time after t at which the expression fires, or the zero time if it never does, such
+// as for the 31st of February.
+func (cron Cron) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+
+	// Every schedule that fires at all fires within the span of a leap cycle.
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if cron.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !cron.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if cron.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if cron.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (cron Cron) matchesDay(t time.Time) bool {
+	dom := cron.dom&(1<<uint(t.Day())) != 0
+	dow := cron.dow&(1<<uint(t.Weekday())) != 0
+	if cron.domStar || cron.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// ScheduleTimes are the upcoming times at which a schedule of a workflow fires.
+type ScheduleTimes struct {
+	Workflow string
+	Cron     string
+	Times    []time.Time
+}
+
+// NextScheduleTimes returns the next n times after t at which each schedule of the workflows fires, ordered by
+// workflow name and then by the order of the schedules within the workflow.
+func (workflows Workflows) NextScheduleTimes(t time.Time, n int) ([]ScheduleTimes, error) {
+	names := maps.Keys(workflows)
+	slices.Sort(names)
+
+	var result []ScheduleTimes
+	for _, name := range names {
+		for _, trigger := range workflows[name].Triggers {
+			cron, err := ParseCron(trigger.Schedule.Cron)
+			if err != nil {
+				return nil, withContext("workflow "+name, "workflows."+name+".triggers", err)
+			}
+
+			schedule := ScheduleTimes{Workflow: name, Cron: trigger.Schedule.Cron}
+			for next := cron.Next(t); !next.IsZero() && len(schedule.Times) < n; next = cron.Next(next) {
+				schedule.Times = append(schedule.Times, next)
+			}
+			result = append(result, schedule)
+		}
+	}
+
+	return result, nil
+}
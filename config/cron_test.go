@@ -0,0 +1,117 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/davidmdm/yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2023-06-14 is a Wednesday.
+	from := time.Date(2023, 6, 14, 10, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		Expr string
+		Next time.Time
+	}{
+		{Expr: "* * * * *", Next: time.Date(2023, 6, 14, 10, 31, 0, 0, time.UTC)},
+		{Expr: "*/15 * * * *", Next: time.Date(2023, 6, 14, 10, 45, 0, 0, time.UTC)},
+		{Expr: "0 3 * * *", Next: time.Date(2023, 6, 15, 3, 0, 0, 0, time.UTC)},
+		{Expr: "0 9-17/4 * * MON-FRI", Next: time.Date(2023, 6, 14, 13, 0, 0, 0, time.UTC)},
+		{Expr: "0 0 * * 7", Next: time.Date(2023, 6, 18, 0, 0, 0, 0, time.UTC)},
+		{Expr: "0 0 1 jan *", Next: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Expr: "0 0 29 2 *", Next: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// When both the day of the month and the day of the week are restricted, either may match.
+		{Expr: "0 0 20 * 5", Next: time.Date(2023, 6, 16, 0, 0, 0, 0, time.UTC)},
+		{Expr: "0 0 31 2 *", Next: time.Time{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Expr, func(t *testing.T) {
+			cron, err := config.ParseCron(tc.Expr)
+			require.NoError(t, err)
+			require.Equal(t, tc.Next, cron.Next(from))
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		for expr, msg := range map[string]string{
+			"* * * *":      `invalid cron expression "* * * *": expected 5 fields but got 4`,
+			"60 * * * *":   `invalid cron expression "60 * * * *": minute value 60 is out of range [0, 59]`,
+			"* * 0 * *":    `invalid cron expression "* * 0 * *": day of month value 0 is out of range [1, 31]`,
+			"* * * foo *":  `invalid cron expression "* * * foo *": invalid month value "foo"`,
+			"*/0 * * * *":  `invalid cron expression "*/0 * * * *": invalid minute step "0"`,
+			"* 10-2 * * *": `invalid cron expression "* 10-2 * * *": invalid hour range 10-2: start is greater than end`,
+		} {
+			_, err := config.ParseCron(expr)
+			require.EqualError(t, err, msg)
+		}
+	})
+}
+
+func TestNextScheduleTimes(t *testing.T) {
+	source := []byte(`version: 2.1
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - run: make test
+workflows:
+  hourly:
+    triggers:
+      - schedule:
+          cron: 0 * * * *
+          filters:
+            branches:
+              only: main
+    jobs:
+      - test
+  weekly:
+    triggers:
+      - schedule:
+          cron: 30 12 * * sun
+          filters:
+            branches:
+              ignore: /feature-.*/
+    jobs:
+      - test
+  main:
+    jobs:
+      - test
+`)
+
+	compiled, err := config.Compiler{}.Compile(source, nil)
+	require.NoError(t, err)
+
+	var result config.Config
+	require.NoError(t, yaml.Unmarshal(compiled, &result))
+
+	schedules, err := result.Workflows.NextScheduleTimes(time.Date(2023, 6, 14, 10, 30, 0, 0, time.UTC), 2)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]config.ScheduleTimes{
+			{
+				Workflow: "hourly",
+				Cron:     "0 * * * *",
+				Times: []time.Time{
+					time.Date(2023, 6, 14, 11, 0, 0, 0, time.UTC),
+					time.Date(2023, 6, 14, 12, 0, 0, 0, time.UTC),
+				},
+			},
+			{
+				Workflow: "weekly",
+				Cron:     "30 12 * * sun",
+				Times: []time.Time{
+					time.Date(2023, 6, 18, 12, 30, 0, 0, time.UTC),
+					time.Date(2023, 6, 25, 12, 30, 0, 0, time.UTC),
+				},
+			},
+		},
+		schedules,
+	)
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity describes how a diagnostic affects compilation.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// ErrorCode identifies the kind of a diagnostic. Codes are stable and safe to match on.
+type ErrorCode string
+
+const (
+	CodeUnknown              ErrorCode = "unknown"
+	CodeSyntax               ErrorCode = "syntax-error"
+	CodeDecode               ErrorCode = "decode-error"
+	CodeEmptyConfig          ErrorCode = "empty-config"
+	CodeNoWorkflows          ErrorCode = "no-workflows"
+	CodeInvalidPipelineArgs  ErrorCode = "invalid-pipeline-arguments"
+	CodeOrbFetch             ErrorCode = "orb-fetch-failed"
+	CodeOrbDigestMismatch    ErrorCode = "orb-digest-mismatch"
+	CodeOrbNotPinned         ErrorCode = "orb-not-pinned"
+	CodeOrbVersionConflict   ErrorCode = "orb-version-conflict"
+	CodeOrbImportCycle       ErrorCode = "orb-import-cycle"
+	CodePackConflict         ErrorCode = "pack-conflict"
+	CodeIncludeFailed        ErrorCode = "include-failed"
+	CodeInvalidContinuation  ErrorCode = "invalid-continuation"
+	CodeInvalidPathMapping   ErrorCode = "invalid-path-mapping"
+	CodeInvalidOrb           ErrorCode = "invalid-orb"
+	CodeMissingDefault       ErrorCode = "missing-default"
+	CodeInvalidParamDecl     ErrorCode = "invalid-param-declaration"
+	CodeParamTypeMismatch    ErrorCode = "param-type-mismatch"
+	CodeParamEnumMismatch    ErrorCode = "param-enum-mismatch"
+	CodeMissingParams        ErrorCode = "missing-params"
+	CodeUnknownArgument      ErrorCode = "unknown-argument"
+	CodeInvalidParamValue    ErrorCode = "invalid-param-value"
+	CodeUndeclaredReference  ErrorCode = "undeclared-reference"
+	CodeJobNotFound          ErrorCode = "job-not-found"
+	CodeExecutorNotFound     ErrorCode = "executor-not-found"
+	CodeCommandNotFound      ErrorCode = "command-not-found"
+	CodeNoSteps              ErrorCode = "no-steps"
+	CodeUnknownRequirement   ErrorCode = "unknown-requirement"
+	CodeDuplicateRequirement ErrorCode = "duplicate-requirement"
+	CodeInvalidRequirement   ErrorCode = "invalid-requirement"
+	CodeUnknownKey           ErrorCode = "unknown-key"
+	CodeRequiresCycle        ErrorCode = "requires-cycle"
+	CodeInvalidMatrix        ErrorCode = "invalid-matrix"
+	CodeInvalidStep          ErrorCode = "invalid-step"
+	CodeInvalidCacheKey      ErrorCode = "invalid-cache-key"
+	CodeInvalidWorkflow      ErrorCode = "invalid-workflow"
+	CodeInvalidTrigger       ErrorCode = "invalid-trigger"
+	CodeInvalidCondition     ErrorCode = "invalid-condition"
+	CodeInvalidEnvironment   ErrorCode = "invalid-environment"
+	CodeInvalidExecutor      ErrorCode = "invalid-executor"
+)
+
+// Diagnostic is the machine readable form of a compile error. Groups of errors, such as all the
+// parameter errors of a job, are represented by a diagnostic whose Causes are the individual errors.
+type Diagnostic struct {
+	Severity Severity  `json:"severity"`
+	Code     ErrorCode `json:"code,omitempty"`
+	Message  string    `json:"message"`
+	// Path is the location of the error within the config, such as workflows.main.jobs[2].steps[1].
+	Path   string       `json:"path,omitempty"`
+	File   string       `json:"file,omitempty"`
+	Line   int          `json:"line,omitempty"`
+	Column int          `json:"column,omitempty"`
+	Causes []Diagnostic `json:"causes,omitempty"`
+}
+
+// Diagnose converts an error returned by the compiler into its diagnostic.
+func Diagnose(err error) Diagnostic {
+	return diagnose(err, "")
+}
+
+func diagnose(err error, path string) Diagnostic {
+	diagnostic := Diagnostic{Severity: SeverityError, Path: path}
+
+	var context []string
+
+	for err != nil {
+		if coder, ok := err.(interface{ Code() ErrorCode }); ok && diagnostic.Code == "" {
+			diagnostic.Code = coder.Code()
+		}
+
+		switch e := err.(type) {
+		case *SourceErr:
+			if diagnostic.Line == 0 {
+				diagnostic.File, diagnostic.Line, diagnostic.Column = e.File, e.Line, e.Column
+			}
+			err = e.Err
+			continue
+
+		case contextErr:
+			if e.Context != "" {
+				context = append(context, e.Context)
+			}
+			diagnostic.Path = joinPath(diagnostic.Path, e.Path)
+			err = e.Err
+			continue
+
+		case codeErr:
+			err = e.Err
+			continue
+
+		case PrettyErr:
+			diagnostic.Message = e.Message
+			diagnostic.Causes = diagnoseAll(e.Errors, diagnostic.Path)
+			sort.SliceStable(diagnostic.Causes, func(i, j int) bool {
+				return naturalLess(diagnostic.Causes[i].String(), diagnostic.Causes[j].String())
+			})
+
+		case OrderedErr:
+			diagnostic.Message = e.Message
+			diagnostic.Causes = diagnoseAll(e.Errors, diagnostic.Path)
+
+		default:
+			diagnostic.Message = err.Error()
+			if diagnostic.Line == 0 {
+				var sourceErr *SourceErr
+				if errors.As(err, &sourceErr) {
+					diagnostic.File, diagnostic.Line, diagnostic.Column = sourceErr.File, sourceErr.Line, sourceErr.Column
+				}
+			}
+		}
+
+		break
+	}
+
+	if diagnostic.Code == "" && len(diagnostic.Causes) == 0 {
+		diagnostic.Code = CodeUnknown
+	}
+
+	if len(context) > 0 {
+		diagnostic.Message = strings.Join(context, ": ") + ": " + diagnostic.Message
+	}
+
+	return diagnostic
+}
+
+func diagnoseAll(errs []error, path string) []Diagnostic {
+	diagnostics := make([]Diagnostic, len(errs))
+	for i, err := range errs {
+		diagnostics[i] = diagnose(err, path)
+	}
+	return diagnostics
+}
+
+func joinPath(base, segment string) string {
+	switch {
+	case segment == "":
+		return base
+	case base == "", strings.HasPrefix(segment, "["):
+		return base + segment
+	default:
+		return base + "." + segment
+	}
+}
+
+// String renders the diagnostic and its causes as nested bullet points, prefixing each message
+// with its location when known.
+func (diagnostic Diagnostic) String() string {
+	message := diagnostic.Message
+	if location := diagnostic.location(); location != "" {
+		message = location + ": " + message
+	}
+
+	if len(diagnostic.Causes) == 0 {
+		return message
+	}
+
+	causes := make([]string, len(diagnostic.Causes))
+	for i, cause := range diagnostic.Causes {
+		causes[i] = indent("- " + cause.String())
+	}
+
+	return message + "\n" + strings.Join(causes, "\n")
+}
+
+func (diagnostic Diagnostic) location() string {
+	var location string
+	switch {
+	case diagnostic.Line == 0:
+		return ""
+	case diagnostic.Column == 0:
+		location = fmt.Sprint(diagnostic.Line)
+	default:
+		location = fmt.Sprintf("%d:%d", diagnostic.Line, diagnostic.Column)
+	}
+	if diagnostic.File != "" {
+		location = diagnostic.File + ":" + location
+	}
+	return location
+}
+
+// contextErr describes where within the config an error occurred, both for humans through
+// Context and as a config path segment through Path.
+type contextErr struct {
+	Context string
+	Path    string
+	Err     error
+}
+
+func withContext(context, path string, err error) error {
+	return contextErr{Context: context, Path: path, Err: err}
+}
+
+func (err contextErr) Error() string {
+	return Diagnose(err).String()
+}
+
+func (err contextErr) Unwrap() error {
+	return err.Err
+}
+
+// codeErr assigns an error code to errors that do not have a dedicated type.
+type codeErr struct {
+	code ErrorCode
+	Err  error
+}
+
+func withCode(code ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	return codeErr{code: code, Err: err}
+}
+
+func (err codeErr) Error() string {
+	return err.Err.Error()
+}
+
+func (err codeErr) Unwrap() error {
+	return err.Err
+}
+
+func (err codeErr) Code() ErrorCode {
+	return err.code
+}
@@ -0,0 +1,109 @@
+package config_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnose(t *testing.T) {
+	source := []byte(`version: 2.1
+
+jobs:
+  test:
+    parameters:
+      size:
+        type: integer
+    docker:
+      - image: go
+    steps:
+      - checkout
+      - missing-command
+
+workflows:
+  main:
+    jobs:
+      - lint
+      - test:
+          size: large
+`)
+
+	compiler := config.Compiler{Filename: "config.yml"}
+
+	_, err := compiler.Compile(source, nil)
+	require.Error(t, err)
+
+	diagnostic := config.Diagnose(err)
+
+	require.Equal(t, err.Error(), diagnostic.String())
+
+	data, err := json.Marshal(diagnostic)
+	require.NoError(t, err)
+
+	require.JSONEq(
+		t,
+		`{
+			"severity": "error",
+			"message": "error processing workflow(s):",
+			"causes": [
+				{
+					"severity": "error",
+					"code": "job-not-found",
+					"message": "workflow main: job lint not found",
+					"path": "workflows.main.jobs[0]",
+					"file": "config.yml",
+					"line": 17,
+					"column": 9
+				}
+			]
+		}`,
+		string(data),
+	)
+
+	source = []byte(`version: 2.1
+
+jobs:
+  test:
+    parameters:
+      size:
+        type: integer
+    docker:
+      - image: go
+    steps:
+      - checkout
+      - missing-command
+
+workflows:
+  main:
+    jobs:
+      - test:
+          size: large
+`)
+
+	_, err = compiler.Compile(source, nil)
+	require.Error(t, err)
+
+	diagnostic = config.Diagnose(err)
+	require.Len(t, diagnostic.Causes, 1)
+
+	jobDiagnostic := diagnostic.Causes[0]
+	require.Equal(t, "workflow main: job test: parameter error(s):", jobDiagnostic.Message)
+	require.Equal(t, "workflows.main.jobs[0]", jobDiagnostic.Path)
+	require.Equal(
+		t,
+		[]config.Diagnostic{
+			{
+				Severity: config.SeverityError,
+				Code:     config.CodeParamTypeMismatch,
+				Message:  "type mismatch for param size: wanted integer but got string",
+				Path:     "workflows.main.jobs[0].size",
+				File:     "config.yml",
+				Line:     18,
+				Column:   17,
+			},
+		},
+		jobDiagnostic.Causes,
+	)
+}
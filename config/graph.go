@@ -0,0 +1,183 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// GraphFormat is a format workflows can be exported to with CompileGraph.
+type GraphFormat string
+
+const (
+	// GraphDOT exports workflows as a Graphviz digraph, with a cluster per workflow.
+	GraphDOT GraphFormat = "dot"
+	// GraphMermaid exports workflows as a Mermaid flowchart, with a subgraph per workflow.
+	GraphMermaid GraphFormat = "mermaid"
+)
+
+// CompileGraph compiles the source config and exports its workflows as a graph of the jobs they run, once
+// conditions, matrices and orbs are resolved. Edges go from each job to the jobs that require it. Approval jobs,
+// contexts and filters are noted on each job.
+func (c Compiler) CompileGraph(source []byte, pipelineParams map[string]any, format GraphFormat) ([]byte, error) {
+	if format != GraphDOT && format != GraphMermaid {
+		return nil, fmt.Errorf("unknown graph format: %s", format)
+	}
+
+	compiled, err := c.compileConfig(source, pipelineParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == GraphMermaid {
+		return []byte(compiled.Workflows.Mermaid()), nil
+	}
+	return []byte(compiled.Workflows.DOT()), nil
+}
+
+// graphJob is a job of a workflow as a node of its graph.
+type graphJob struct {
+	id    string
+	name  string
+	notes []string
+	// approval is set for approval jobs, which are drawn with a distinct shape.
+	approval bool
+}
+
+// graphEdge goes from a required job to the job requiring it. Its label holds the statuses the required job
+// must end with, when other than success.
+type graphEdge struct {
+	from, to string
+	label    string
+}
+
+// graph walks the workflows in name order, calling workflow with the id and name of each workflow, its jobs and
+// the edges between them.
+func (workflows Workflows) graph(workflow func(id, name string, jobs []graphJob, edges []graphEdge)) {
+	names := maps.Keys(workflows)
+	slices.Sort(names)
+
+	for i, name := range names {
+		var (
+			jobs  []graphJob
+			edges []graphEdge
+			ids   = map[string]string{}
+		)
+
+		for j, wfJob := range workflows[name].Jobs {
+			job := graphJob{
+				id:       fmt.Sprintf("w%d_j%d", i, j),
+				name:     wfJob.Name(),
+				approval: wfJob.Type == "approval",
+			}
+			ids[job.name] = job.id
+
+			if job.approval {
+				job.notes = append(job.notes, "approval")
+			}
+			if len(wfJob.Context) > 0 {
+				job.notes = append(job.notes, "context: "+strings.Join(wfJob.Context, ", "))
+			}
+			job.notes = append(job.notes, filterNotes("branches", wfJob.Filters.Branches)...)
+			job.notes = append(job.notes, filterNotes("tags", wfJob.Filters.Tags)...)
+
+			jobs = append(jobs, job)
+		}
+
+		for j, wfJob := range workflows[name].Jobs {
+			for _, required := range wfJob.Requires {
+				if id, ok := ids[required.Job]; ok {
+					edges = append(edges, graphEdge{from: id, to: jobs[j].id, label: strings.Join(required.Status, ", ")})
+				}
+			}
+		}
+
+		workflow(fmt.Sprintf("w%d", i), name, jobs, edges)
+	}
+}
+
+func filterNotes(kind string, conditions FilterConditions) []string {
+	var notes []string
+	if len(conditions.Only) > 0 {
+		notes = append(notes, kind+": only "+strings.Join(conditions.Only, ", "))
+	}
+	if len(conditions.Ignore) > 0 {
+		notes = append(notes, kind+": ignore "+strings.Join(conditions.Ignore, ", "))
+	}
+	return notes
+}
+
+// DOT returns the workflows as a Graphviz digraph.
+func (workflows Workflows) DOT() string {
+	var b strings.Builder
+
+	b.WriteString("digraph workflows {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+
+	workflows.graph(func(id, name string, jobs []graphJob, edges []graphEdge) {
+		fmt.Fprintf(&b, "\n  subgraph cluster_%s {\n", id)
+		fmt.Fprintf(&b, "    label=%s;\n", dotQuote(name))
+		for _, job := range jobs {
+			attrs := "label=" + dotQuote(strings.Join(append([]string{job.name}, job.notes...), "\n"))
+			if job.approval {
+				attrs += ", shape=diamond"
+			}
+			fmt.Fprintf(&b, "    %s [%s];\n", job.id, attrs)
+		}
+		for _, edge := range edges {
+			if edge.label != "" {
+				fmt.Fprintf(&b, "    %s -> %s [label=%s];\n", edge.from, edge.to, dotQuote(edge.label))
+				continue
+			}
+			fmt.Fprintf(&b, "    %s -> %s;\n", edge.from, edge.to)
+		}
+		b.WriteString("  }\n")
+	})
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// Mermaid returns the workflows as a Mermaid flowchart.
+func (workflows Workflows) Mermaid() string {
+	var b strings.Builder
+
+	b.WriteString("flowchart LR\n")
+
+	workflows.graph(func(id, name string, jobs []graphJob, edges []graphEdge) {
+		fmt.Fprintf(&b, "  subgraph %s[%s]\n", id, mermaidQuote(name))
+		for _, job := range jobs {
+			label := mermaidQuote(strings.Join(append([]string{job.name}, job.notes...), "<br/>"))
+			if job.approval {
+				fmt.Fprintf(&b, "    %s{{%s}}\n", job.id, label)
+			} else {
+				fmt.Fprintf(&b, "    %s[%s]\n", job.id, label)
+			}
+		}
+		for _, edge := range edges {
+			if edge.label != "" {
+				fmt.Fprintf(&b, "    %s -->|%s| %s\n", edge.from, mermaidQuote(edge.label), edge.to)
+				continue
+			}
+			fmt.Fprintf(&b, "    %s --> %s\n", edge.from, edge.to)
+		}
+		b.WriteString("  end\n")
+	})
+
+	return b.String()
+}
+
+func dotQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
+
+func mermaidQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,117 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileGraph(t *testing.T) {
+	source := []byte(`version: 2.1
+jobs:
+  test:
+    parameters:
+      go:
+        type: string
+        default: "1.20"
+    docker:
+      - image: go:<< parameters.go >>
+    steps:
+      - run: make test
+  deploy:
+    docker:
+      - image: go
+    steps:
+      - run: make deploy
+workflows:
+  release:
+    jobs:
+      - test:
+          matrix:
+            parameters:
+              go: ["1.20", "1.21"]
+      - hold:
+          type: approval
+          requires: [test]
+      - deploy:
+          requires: [hold]
+          context: [aws, "prod \"secrets\""]
+          filters:
+            branches:
+              only: main
+            tags:
+              ignore: /.*/
+`)
+
+	dot, err := config.Compiler{}.CompileGraph(source, nil, config.GraphDOT)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		`digraph workflows {
+  rankdir=LR;
+  node [shape=box];
+
+  subgraph cluster_w0 {
+    label="release";
+    w0_j0 [label="test-1.20"];
+    w0_j1 [label="test-1.21"];
+    w0_j2 [label="hold\napproval", shape=diamond];
+    w0_j3 [label="deploy\ncontext: aws, prod \"secrets\"\nbranches: only main\ntags: ignore /.*/"];
+    w0_j0 -> w0_j2;
+    w0_j1 -> w0_j2;
+    w0_j2 -> w0_j3;
+  }
+}
+`,
+		string(dot),
+	)
+
+	mermaid, err := config.Compiler{}.CompileGraph(source, nil, config.GraphMermaid)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		`flowchart LR
+  subgraph w0["release"]
+    w0_j0["test-1.20"]
+    w0_j1["test-1.21"]
+    w0_j2{{"hold<br/>approval"}}
+    w0_j3["deploy<br/>context: aws, prod #quot;secrets#quot;<br/>branches: only main<br/>tags: ignore /.*/"]
+    w0_j0 --> w0_j2
+    w0_j1 --> w0_j2
+    w0_j2 --> w0_j3
+  end
+`,
+		string(mermaid),
+	)
+
+	t.Run("requirement statuses", func(t *testing.T) {
+		source := []byte(`version: 2.1
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - run: make test
+workflows:
+  main:
+    jobs:
+      - test
+      - test:
+          name: cleanup
+          requires:
+            - test: [failed, canceled]
+`)
+
+		dot, err := config.Compiler{}.CompileGraph(source, nil, config.GraphDOT)
+		require.NoError(t, err)
+		require.Contains(t, string(dot), `w0_j0 -> w0_j1 [label="failed, canceled"];`)
+
+		mermaid, err := config.Compiler{}.CompileGraph(source, nil, config.GraphMermaid)
+		require.NoError(t, err)
+		require.Contains(t, string(mermaid), `w0_j0 -->|"failed, canceled"| w0_j1`)
+	})
+
+	_, err = config.Compiler{}.CompileGraph(source, nil, "svg")
+	require.EqualError(t, err, "unknown graph format: svg")
+}
@@ -1,28 +1,21 @@
 package config
 
 import (
-	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 
-	"github.com/davidmdm/handlebars"
 	"github.com/davidmdm/yaml"
 )
 
 var (
 	paramExpr         = regexp.MustCompile(`<<(\s*parameters\.[\w-]+)\s*>>`)
 	pipelineParamExpr = regexp.MustCompile(`<<\s*pipeline\.[\w-]+(\.[\w-]+)*\s*>>`)
+	matrixParamExpr   = regexp.MustCompile(`<<\s*matrix\.([\w-]+)\s*>>`)
 )
 
-func toHandlebars(source string, expr *regexp.Regexp) string {
-	return expr.ReplaceAllStringFunc(source, func(s string) string {
-		raw := []byte(s)
-		raw[0], raw[1], raw[len(raw)-2], raw[len(raw)-1] = '{', '{', '}', '}'
-		return string(raw)
-	})
-}
-
 func applyParams[T any](node *yaml.Node, params map[string]any) (*T, error) {
 	return apply[T](node, paramExpr, map[string]any{"parameters": params})
 }
@@ -31,58 +24,192 @@ func applyPipelineParams[T any](node *yaml.Node, params map[string]any) (*T, err
 	return apply[T](node, pipelineParamExpr, map[string]any{"pipeline": params})
 }
 
+// apply decodes a copy of node into T, with the references matched by expr substituted with their values
+// within params. Substitution is done on the scalars of the tree, such that values cannot change its structure.
 func apply[T any](node *yaml.Node, expr *regexp.Regexp, params map[string]any) (*T, error) {
-	var template bytes.Buffer
-	if err := yaml.NewEncoder(&template).Encode(node); err != nil {
+	if expr != nil {
+		var errs []error
+
+		for _, ref := range templateReferences(node, expr) {
+			if _, ok := lookupReference(params, ref.Path); !ok {
+				errs = append(errs, errAt(ref.Node, withCode(CodeUndeclaredReference, errors.New(ref.Path))))
+			}
+		}
+
+		if len(errs) > 0 {
+			return nil, PrettyErr{Message: "argument(s) referenced in template but not declared:", Errors: errs}
+		}
+	}
+
+	result, err := substitute(node, expr, params)
+	if err != nil {
+		return nil, errAt(node, err)
+	}
+
+	var dst T
+	if err := decode(result, &dst); err != nil {
 		return nil, err
 	}
 
-	raw, err := func() ([]byte, error) {
-		if expr == nil {
-			return template.Bytes(), nil
+	return &dst, nil
+}
+
+// substitute returns a copy of node with the references matched by expr substituted. A plain scalar that
+// consists of a single reference takes the value as is, such as an integer or a list of steps, while
+// references within other scalars are interpolated as strings. Substituted values take the position of the
+// scalar that references them. References escaped as \<< are emitted as literal text. A nil expr substitutes
+// nothing, such as for workflows whose arguments are never substituted, but still unescapes references.
+func substitute(node *yaml.Node, expr *regexp.Regexp, params map[string]any) (*yaml.Node, error) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		return substitute(node.Alias, expr, params)
+	}
+
+	result := *node
+
+	if node.Kind == yaml.ScalarNode && expr == nil {
+		if value := replaceReferences(node.Value, paramExpr, func(ref string) string { return ref }); value != node.Value {
+			result.Value, result.Tag = value, "!!str"
 		}
-		tpl, err := handlebars.Parse(toHandlebars(template.String(), expr))
-		if err != nil {
+		return &result, nil
+	}
+
+	if node.Kind != yaml.ScalarNode || expr == nil || !expr.MatchString(node.Value) {
+		result.Content = make([]*yaml.Node, len(node.Content))
+		for i, child := range node.Content {
+			var err error
+			if result.Content[i], err = substitute(child, expr, params); err != nil {
+				return nil, err
+			}
+		}
+		return &result, nil
+	}
+
+	if refs := findReferences(node.Value, expr); node.Style == 0 && len(refs) == 1 && refs[0] == node.Value {
+		value, _ := lookupReference(params, referencePath(node.Value))
+
+		var valueNode yaml.Node
+		if err := valueNode.Encode(value); err != nil {
 			return nil, err
 		}
 
-		var errs []error
+		setPositions(&valueNode, positionOf(node))
+		valueNode.HeadComment, valueNode.LineComment, valueNode.FootComment = node.HeadComment, node.LineComment, node.FootComment
 
-	outer:
-		for _, expr := range tpl.ExpressionPaths() {
-			if !strings.HasPrefix(expr, "pipeline.") && !strings.HasPrefix(expr, "parameters.") {
-				continue
-			}
-			current := params
-			for _, pathSegment := range strings.Split(expr, ".") {
-				value, ok := current[pathSegment]
-				if !ok {
-					errs = append(errs, errors.New(expr))
-					continue outer
-				}
-				current, _ = value.(map[string]any)
-			}
+		return &valueNode, nil
+	}
+
+	var errs []error
+
+	result.Value = replaceReferences(node.Value, expr, func(ref string) string {
+		value, _ := lookupReference(params, referencePath(ref))
+		str, err := interpolate(value)
+		if err != nil {
+			errs = append(errs, err)
 		}
+		return str
+	})
+	result.Tag = "!!str"
 
-		if len(errs) > 0 {
-			return nil, PrettyErr{Message: "argument(s) referenced in template but not declared:", Errors: errs}
+	return &result, errors.Join(errs...)
+}
+
+// replaceReferences replaces the references matched by expr within value with the result of repl. References
+// escaped with a backslash, such as \<< parameters.key >>, are left as is without their backslash.
+func replaceReferences(value string, expr *regexp.Regexp, repl func(ref string) string) string {
+	var (
+		result strings.Builder
+		last   int
+	)
+	for _, loc := range expr.FindAllStringIndex(value, -1) {
+		start, end := loc[0], loc[1]
+		if start > 0 && value[start-1] == '\\' {
+			result.WriteString(value[last : start-1])
+			result.WriteString(value[start:end])
+		} else {
+			result.WriteString(value[last:start])
+			result.WriteString(repl(value[start:end]))
 		}
+		last = end
+	}
+	result.WriteString(value[last:])
+	return result.String()
+}
+
+// findReferences returns the references matched by expr within value that are not escaped.
+func findReferences(value string, expr *regexp.Regexp) []string {
+	var refs []string
+	for _, loc := range expr.FindAllStringIndex(value, -1) {
+		if loc[0] > 0 && value[loc[0]-1] == '\\' {
+			continue
+		}
+		refs = append(refs, value[loc[0]:loc[1]])
+	}
+	return refs
+}
+
+// interpolate formats a value referenced within a string. Values that are not scalars, such as steps, are
+// formatted as JSON.
+func interpolate(value any) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case int, bool, float64:
+		return fmt.Sprint(value), nil
+	}
 
-		raw, err := tpl.Exec(params)
-		return []byte(raw), err
-	}()
+	raw, err := yaml.Marshal(value)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	// _ = os.WriteFile("./output.template.debug", template.Bytes(), 0o777)
-	// _ = os.WriteFile("./output.handlebard.debug", []byte(handlebarTmpl), 0o777)
-	// _ = os.WriteFile("./output.debug", []byte(raw), 0o777)
+	var generic any
+	if err := yaml.Unmarshal(raw, &generic); err != nil {
+		return "", err
+	}
 
-	var dst T
-	if err := yaml.Unmarshal(raw, &dst); err != nil {
-		return nil, err
+	raw, err = json.Marshal(generic)
+	return string(raw), err
+}
+
+// referencePath returns the path of a reference, such as parameters.key for << parameters.key >>.
+func referencePath(ref string) string {
+	return strings.TrimSpace(ref[2 : len(ref)-2])
+}
+
+// lookupReference returns the value at the dot separated path within params.
+func lookupReference(params map[string]any, path string) (any, bool) {
+	var (
+		current = params
+		value   any
+	)
+	for _, segment := range strings.Split(path, ".") {
+		var ok bool
+		if value, ok = current[segment]; !ok {
+			return nil, false
+		}
+		current, _ = value.(map[string]any)
 	}
+	return value, true
+}
 
-	return &dst, nil
+type templateReference struct {
+	Path string
+	Node *yaml.Node
+}
+
+// templateReferences returns the parameter paths referenced by the scalars of node, along with the
+// node that references them.
+func templateReferences(node *yaml.Node, expr *regexp.Regexp) []templateReference {
+	var refs []templateReference
+	if node.Kind == yaml.ScalarNode {
+		for _, match := range findReferences(node.Value, expr) {
+			refs = append(refs, templateReference{Path: referencePath(match), Node: node})
+		}
+	}
+	for _, child := range node.Content {
+		refs = append(refs, templateReferences(child, expr)...)
+	}
+	return refs
 }
@@ -1,50 +1,47 @@
 package config
 
 import (
+	"regexp"
 	"testing"
 
+	"github.com/davidmdm/yaml"
 	"github.com/stretchr/testify/require"
 )
 
-func TestToHandleBars(t *testing.T) {
+func TestSubstituteReferences(t *testing.T) {
+	params := map[string]any{
+		"parameters": map[string]any{"key": "value"},
+		"pipeline":   map[string]any{"id": "value", "parameters": map[string]any{"key": "value"}},
+	}
+
+	substituted := func(t *testing.T, input string, expr *regexp.Regexp) string {
+		t.Helper()
+		result, err := substitute(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: input}, expr, params)
+		require.NoError(t, err)
+		return result.Value
+	}
+
 	t.Run("params", func(t *testing.T) {
 		cases := []struct {
 			Input  string
 			Output string
 		}{
-			{
-				Input:  "<< parameters.key >>",
-				Output: "{{ parameters.key }}",
-			},
-			{
-				Input:  "<<parameters.key>>",
-				Output: "{{parameters.key}}",
-			},
-			{
-				Input:  "<< parameters.key>>",
-				Output: "{{ parameters.key}}",
-			},
-			{
-				Input:  "<< parameters.key>>",
-				Output: "{{ parameters.key}}",
-			},
-			{
-				Input:  "<< pipeline.parameters.key >>",
-				Output: "<< pipeline.parameters.key >>",
-			},
-			{
-				Input:  "<<pipeline.parameters.key>>",
-				Output: "<<pipeline.parameters.key>>",
-			},
-			{
-				Input:  "<<random.var>>",
-				Output: "<<random.var>>",
-			},
+			{Input: "<< parameters.key >>", Output: "value"},
+			{Input: "<<parameters.key>>", Output: "value"},
+			{Input: "<< parameters.key>>", Output: "value"},
+			{Input: "image:<< parameters.key >>", Output: "image:value"},
+			{Input: "<< pipeline.parameters.key >>", Output: "<< pipeline.parameters.key >>"},
+			{Input: "<<pipeline.parameters.key>>", Output: "<<pipeline.parameters.key>>"},
+			{Input: "<<random.var>>", Output: "<<random.var>>"},
+			{Input: `\<< parameters.key >>`, Output: "<< parameters.key >>"},
+			{Input: `usage: \<< parameters.key >> is << parameters.key >>`, Output: "usage: << parameters.key >> is value"},
+			{Input: `\<< parameters.undeclared >>`, Output: "<< parameters.undeclared >>"},
+			{Input: `\<< pipeline.id >>`, Output: `\<< pipeline.id >>`},
 		}
 
 		for _, tc := range cases {
 			t.Run(tc.Input, func(t *testing.T) {
-				require.Equal(t, tc.Output, toHandlebars(tc.Input, paramExpr))
+				require.Equal(t, tc.Output, substituted(t, tc.Input, paramExpr))
 			})
 		}
 	})
@@ -54,36 +51,174 @@ func TestToHandleBars(t *testing.T) {
 			Input  string
 			Output string
 		}{
-			{
-				Input:  "<< parameters.key >>",
-				Output: "<< parameters.key >>",
-			},
-			{
-				Input:  "<< pipeline >>",
-				Output: "<< pipeline >>",
-			},
-			{
-				Input:  "<< pipeline.id >>",
-				Output: "{{ pipeline.id }}",
-			},
-			{
-				Input:  "<< pipeline.parameters.key >>",
-				Output: "{{ pipeline.parameters.key }}",
-			},
-			{
-				Input:  "<<pipeline.parameters.key>>",
-				Output: "{{pipeline.parameters.key}}",
-			},
-			{
-				Input:  "<<random.var>>",
-				Output: "<<random.var>>",
-			},
+			{Input: "<< parameters.key >>", Output: "<< parameters.key >>"},
+			{Input: "<< pipeline >>", Output: "<< pipeline >>"},
+			{Input: "<< pipeline.id >>", Output: "value"},
+			{Input: "<< pipeline.parameters.key >>", Output: "value"},
+			{Input: "<<pipeline.parameters.key>>", Output: "value"},
+			{Input: "<<random.var>>", Output: "<<random.var>>"},
+			{Input: `\<< pipeline.id >>`, Output: "<< pipeline.id >>"},
+			{Input: `\<< pipeline.id >>-<< pipeline.id >>`, Output: "<< pipeline.id >>-value"},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.Input, func(t *testing.T) {
+				require.Equal(t, tc.Output, substituted(t, tc.Input, pipelineParamExpr))
+			})
+		}
+	})
+
+	t.Run("no expression", func(t *testing.T) {
+		cases := []struct {
+			Input  string
+			Output string
+		}{
+			{Input: "<< parameters.key >>", Output: "<< parameters.key >>"},
+			{Input: `\<< parameters.key >>`, Output: "<< parameters.key >>"},
+			{Input: `echo \<< parameters.key >> << parameters.key >>`, Output: "echo << parameters.key >> << parameters.key >>"},
 		}
 
 		for _, tc := range cases {
 			t.Run(tc.Input, func(t *testing.T) {
-				require.Equal(t, tc.Output, toHandlebars(tc.Input, pipelineParamExpr))
+				require.Equal(t, tc.Output, substituted(t, tc.Input, nil))
 			})
 		}
 	})
 }
+
+func TestEscapedReferences(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Source string
+		Output string
+	}{
+		{
+			Name: "job",
+			Source: `jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - run: echo \<< parameters.name >> \<< pipeline.git.branch >>
+workflows:
+  main:
+    jobs:
+      - test`,
+			Output: "command: echo << parameters.name >> << pipeline.git.branch >>",
+		},
+		{
+			Name: "command",
+			Source: `commands:
+  usage:
+    parameters:
+      flag:
+        type: string
+        default: --verbose
+    steps:
+      - run: echo deploy \<< parameters.flag >> defaults to << parameters.flag >>
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - usage
+workflows:
+  main:
+    jobs:
+      - test`,
+			Output: "command: echo deploy << parameters.flag >> defaults to --verbose",
+		},
+		{
+			Name: "executor",
+			Source: `executors:
+  go:
+    parameters:
+      tag:
+        type: string
+        default: "1.20"
+    docker:
+      - image: go:<< parameters.tag >>
+        environment:
+          USAGE: \<< parameters.tag >>
+jobs:
+  test:
+    executor: go
+    steps:
+      - checkout
+workflows:
+  main:
+    jobs:
+      - test`,
+			Output: "USAGE: << parameters.tag >>",
+		},
+		{
+			Name: "workflow argument",
+			Source: `jobs:
+  test:
+    parameters:
+      message:
+        type: string
+    docker:
+      - image: go
+    steps:
+      - run: echo << parameters.message >>
+workflows:
+  main:
+    jobs:
+      - test:
+          message: \<< parameters.name >>`,
+			Output: "command: echo << parameters.name >>",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			compiled, err := Compiler{}.Compile([]byte("version: 2.1\n"+tc.Source+"\n"), nil)
+			require.NoError(t, err)
+			require.Contains(t, string(compiled), tc.Output)
+		})
+	}
+}
+
+func TestApplyParams(t *testing.T) {
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`
+count: << parameters.count >>
+enabled: << parameters.enabled >>
+quoted: "<< parameters.count >>"
+message: << parameters.message >>
+command: echo << parameters.message >>
+steps: << parameters.steps >>
+`), &node))
+
+	params := map[string]any{
+		"count":   3,
+		"enabled": true,
+		"message": "hello\ninjected: true # comment",
+		"steps":   []any{"checkout", map[string]any{"run": "make"}},
+	}
+
+	result, err := applyParams[map[string]any](node.Content[0], params)
+	require.NoError(t, err)
+
+	require.Equal(
+		t,
+		map[string]any{
+			"count":   3,
+			"enabled": true,
+			"quoted":  "3",
+			"message": "hello\ninjected: true # comment",
+			"command": "echo hello\ninjected: true # comment",
+			"steps":   []any{"checkout", map[string]any{"run": "make"}},
+		},
+		*result,
+	)
+
+	// The original node is left untouched, such that it can be applied with other parameters.
+	require.Equal(t, "<< parameters.count >>", node.Content[0].Content[1].Value)
+
+	// Substituted values take the position of the reference.
+	substituted, err := substitute(node.Content[0], paramExpr, map[string]any{"parameters": params})
+	require.NoError(t, err)
+	require.Equal(t, 7, substituted.Content[11].Content[1].Line)
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/davidmdm/yaml"
+)
+
+var includeExpr = regexp.MustCompile(`<<\s*include\(([^)]*)\)\s*>>`)
+
+// resolveIncludes replaces every string of the form <<include(path)>> with the content of the file at path
+// within root. The directive must be the entire string, and may not reach outside of root.
+func resolveIncludes(root fs.FS, node *yaml.Node) error {
+	var errs []error
+
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		for _, child := range node.Content {
+			walk(child)
+		}
+
+		if node.Kind != yaml.ScalarNode || !includeExpr.MatchString(node.Value) {
+			return
+		}
+
+		content, err := readInclude(root, node.Value)
+		if err != nil {
+			errs = append(errs, errAt(node, withCode(CodeIncludeFailed, err)))
+			return
+		}
+
+		node.Value = content
+		node.Tag = "!!str"
+		node.Style = 0
+	}
+
+	walk(node)
+
+	if len(errs) > 0 {
+		return PrettyErr{Message: "failed to resolve include(s):", Errors: errs}
+	}
+
+	return nil
+}
+
+func readInclude(root fs.FS, value string) (string, error) {
+	match := includeExpr.FindStringSubmatch(value)
+	if match[0] != strings.TrimSpace(value) {
+		return "", fmt.Errorf("include directive must be the entire value but got: %s", value)
+	}
+
+	filename := strings.TrimSpace(match[1])
+	if filename == "" {
+		return "", errors.New("include directive requires a path")
+	}
+
+	if cleaned := path.Clean(filename); path.IsAbs(cleaned) || !fs.ValidPath(cleaned) {
+		return "", fmt.Errorf("cannot include %s: path is outside of the include root", filename)
+	} else {
+		filename = cleaned
+	}
+
+	if root == nil {
+		return "", fmt.Errorf("cannot include %s: no include root configured", filename)
+	}
+
+	data, err := fs.ReadFile(root, filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("cannot include %s: file not found", filename)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot include %s: %w", filename, err)
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,76 @@
+package config_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"scripts/greet.sh": {Data: []byte("#!/bin/bash\necho \"hello << parameters.target >>: it's #1\"\n")},
+		"scripts/true.sh":  {Data: []byte(`true: "yes"`)},
+	}
+
+	source := []byte(`version: 2.1
+jobs:
+  test:
+    parameters:
+      target:
+        type: string
+        default: world
+    docker:
+      - image: go
+    steps:
+      - run:
+          name: greet
+          command: <<include(scripts/greet.sh)>>
+      - run: << include(./scripts/true.sh) >>
+workflows:
+  main:
+    jobs:
+      - test
+`)
+
+	compiled, err := config.Compiler{IncludeFS: fsys}.Compile(source, nil)
+	require.NoError(t, err)
+
+	commands := runCommands(t, compiled, "test")
+	require.Equal(t, "#!/bin/bash\necho \"hello world: it's #1\"\n", commands[0])
+	require.Equal(t, `true: "yes"`, commands[1])
+
+	t.Run("errors", func(t *testing.T) {
+		source := []byte(`version: 2.1
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - run: <<include(missing.sh)>>
+      - run: <<include(../secrets.sh)>>
+      - run: <<include(/etc/passwd)>>
+      - run: echo <<include(scripts/true.sh)>>
+workflows:
+  main:
+    jobs:
+      - test
+`)
+
+		_, err := config.Compiler{IncludeFS: fsys, Filename: "config.yml"}.Compile(source, nil)
+		require.EqualError(
+			t,
+			err,
+			"failed to resolve include(s):\n"+
+				"  - config.yml:7:14: cannot include missing.sh: file not found\n"+
+				"  - config.yml:8:14: cannot include ../secrets.sh: path is outside of the include root\n"+
+				"  - config.yml:9:14: cannot include /etc/passwd: path is outside of the include root\n"+
+				"  - config.yml:10:14: include directive must be the entire value but got: echo <<include(scripts/true.sh)>>",
+		)
+		require.Equal(t, config.CodeIncludeFailed, config.Diagnose(err).Causes[0].Code)
+
+		_, err = config.Compiler{}.Compile([]byte("version: 2.1\ncommand: <<include(a.sh)>>\n"), nil)
+		require.EqualError(t, err, "failed to resolve include(s):\n  - 2:10: cannot include a.sh: no include root configured")
+	})
+}
@@ -1,6 +1,11 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
@@ -10,6 +15,156 @@ type KV struct {
 	Value any
 }
 
+// matrixInstance is one of the jobs a workflow job expands to, one per combination of its matrix.
+type matrixInstance struct {
+	Values []KV
+	// Name is the name of the compiled job, which is empty for workflow jobs without a matrix.
+	Name string
+	// Names are the names by which other jobs of the workflow may require the instance.
+	Names    []string
+	Requires Requirements
+}
+
+// expandMatrix returns the instances of the workflow job. Matrix jobs are named after the values of their
+// combination, such as test-1.20-linux, unless their name references the matrix with << matrix.* >>.
+// References to the matrix within requires are replaced by the values of each instance.
+func expandMatrix(job WorkflowJob) ([]matrixInstance, error) {
+	if len(job.Matrix.Parameters) == 0 {
+		requires, err := expandRequirementRefs(job.Requires, nil)
+		if err != nil {
+			return nil, withContext("", "requires", errAtPosition(job.pos, err))
+		}
+		return []matrixInstance{{Names: []string{job.Name()}, Requires: requires}}, nil
+	}
+
+	rows, err := excludeMatrix(flattenKeyedMatrix(job.Matrix.Parameters), job.Matrix, job.pos)
+	if err != nil {
+		return nil, err
+	}
+
+	alias := job.Matrix.Alias
+	if alias == "" {
+		alias = job.Name()
+		if matrixParamExpr.MatchString(alias) {
+			alias = job.Key
+		}
+	}
+
+	instances := make([]matrixInstance, len(rows))
+
+	for i, row := range rows {
+		var name string
+		if matrixParamExpr.MatchString(job.Name()) {
+			names, err := expandMatrixRefs([]string{job.Name()}, row)
+			if err != nil {
+				return nil, withContext("", "name", errAtPosition(job.pos, err))
+			}
+			name = names[0]
+		} else {
+			values := make([]string, len(row))
+			for j, kv := range row {
+				values[j] = fmt.Sprint(kv.Value)
+			}
+			name = job.Name() + "-" + strings.Join(values, "-")
+		}
+
+		requires, err := expandRequirementRefs(job.Requires, row)
+		if err != nil {
+			return nil, withContext("", "requires", errAtPosition(job.pos, err))
+		}
+
+		instances[i] = matrixInstance{
+			Values:   row,
+			Name:     name,
+			Names:    []string{alias, name},
+			Requires: requires,
+		}
+	}
+
+	return instances, nil
+}
+
+// validateMatrixNames reports matrix instances of the workflow that are named the same, such as when their name
+// references only some of the matrix parameters, as only one of them would be compiled.
+func validateMatrixNames(workflow *Workflow, instances [][]matrixInstance) []error {
+	type namedInstance struct {
+		key    string
+		values []KV
+	}
+
+	var (
+		errs  []error
+		named = map[string]namedInstance{}
+	)
+
+	for i, wfJob := range workflow.Jobs {
+		for _, instance := range instances[i] {
+			if instance.Name == "" {
+				continue
+			}
+			current := namedInstance{key: wfJob.Key, values: instance.Values}
+			previous, ok := named[instance.Name]
+			if !ok {
+				named[instance.Name] = current
+				continue
+			}
+			err := withCode(CodeInvalidMatrix, fmt.Errorf(
+				"job name %s is used by both %s and %s",
+				instance.Name, describeMatrixRow(previous.key, previous.values), describeMatrixRow(current.key, current.values),
+			))
+			errs = append(errs, withContext("", fmt.Sprintf("jobs[%d].name", i), errAtPosition(wfJob.pos, err)))
+		}
+	}
+
+	return errs
+}
+
+// describeMatrixRow describes a matrix instance of a job by its values, such as test (a=1, b=x).
+func describeMatrixRow(key string, row []KV) string {
+	values := make([]string, len(row))
+	for i, kv := range row {
+		values[i] = fmt.Sprintf("%s=%v", kv.Key, kv.Value)
+	}
+	return fmt.Sprintf("%s (%s)", key, strings.Join(values, ", "))
+}
+
+// expandMatrixRefs replaces the << matrix.* >> references within values by the values of the matrix row.
+func expandMatrixRefs(values []string, row []KV) ([]string, error) {
+	result := make([]string, len(values))
+
+	for i, value := range values {
+		for _, match := range matrixParamExpr.FindAllStringSubmatch(value, -1) {
+			if !slices.ContainsFunc(row, func(kv KV) bool { return kv.Key == match[1] }) {
+				return nil, withCode(CodeInvalidMatrix, fmt.Errorf("%s references unknown matrix parameter %s", value, match[1]))
+			}
+		}
+
+		result[i] = matrixParamExpr.ReplaceAllStringFunc(value, func(ref string) string {
+			key := matrixParamExpr.FindStringSubmatch(ref)[1]
+			idx := slices.IndexFunc(row, func(kv KV) bool { return kv.Key == key })
+			return fmt.Sprint(row[idx].Value)
+		})
+	}
+
+	return result, nil
+}
+
+// expandRequirementRefs replaces the << matrix.* >> references within the names of the required jobs by the
+// values of the matrix row.
+func expandRequirementRefs(requires Requirements, row []KV) (Requirements, error) {
+	jobs, err := expandMatrixRefs(requires.Jobs(), row)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(Requirements, len(requires))
+	for i, requirement := range requires {
+		result[i] = Requirement{Job: jobs[i], Status: requirement.Status}
+	}
+
+	return result, nil
+}
+
 func flattenKeyedMatrix(m map[string][]any) [][]KV {
 	keys := maps.Keys(m)
 	slices.Sort(keys)
@@ -36,6 +191,82 @@ func flattenKeyedMatrix(m map[string][]any) [][]KV {
 	return result
 }
 
+// excludeMatrix removes the rows of the matrix matched by its exclude entries. An entry matches the rows
+// whose values equal every value of the entry. Entries must reference parameters of the matrix and match
+// at least one row, and may not exclude every row. Errors without a more precise position are positioned at.
+func excludeMatrix(rows [][]KV, matrix JobMatrix, at Position) ([][]KV, error) {
+	if len(matrix.Exclude) == 0 {
+		return rows, nil
+	}
+
+	var errs []error
+
+	excluded := make([]bool, len(rows))
+
+	for i, exclude := range matrix.Exclude {
+		pos := at
+		if i < len(matrix.excludePos) {
+			pos = matrix.excludePos[i]
+		}
+
+		excludeErr := func(err error) {
+			errs = append(errs, withContext("", fmt.Sprintf("matrix.exclude[%d]", i), errAtPosition(pos, withCode(CodeInvalidMatrix, err))))
+		}
+
+		keys := maps.Keys(exclude)
+		slices.Sort(keys)
+
+		var unknown bool
+		for _, key := range keys {
+			if _, ok := matrix.Parameters[key]; !ok {
+				excludeErr(fmt.Errorf("exclude references unknown matrix parameter %s", key))
+				unknown = true
+			}
+		}
+		if unknown {
+			continue
+		}
+
+		var matched bool
+		for j, row := range rows {
+			if matchesExclude(row, exclude) {
+				excluded[j] = true
+				matched = true
+			}
+		}
+		if !matched {
+			excludeErr(errors.New("exclude does not match any combination of the matrix"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, PrettyErr{Message: "matrix exclude error(s):", Errors: errs}
+	}
+
+	var result [][]KV
+	for i, row := range rows {
+		if !excluded[i] {
+			result = append(result, row)
+		}
+	}
+
+	if len(result) == 0 {
+		err := withCode(CodeInvalidMatrix, errors.New("exclude removes every combination of the matrix"))
+		return nil, withContext("", "matrix.exclude", errAtPosition(at, err))
+	}
+
+	return result, nil
+}
+
+func matchesExclude(row []KV, exclude map[string]any) bool {
+	for _, kv := range row {
+		if value, ok := exclude[kv.Key]; ok && !reflect.DeepEqual(value, kv.Value) {
+			return false
+		}
+	}
+	return true
+}
+
 func crossProduct[T any](m [][]T) [][]T {
 	if len(m) == 0 {
 		return nil
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// ErrOrbNotCached is returned by an offline CacheResolver when an orb is not in its cache.
+var ErrOrbNotCached = errors.New("orb not cached")
+
+// DefaultOrbCacheDir returns the directory orbs are cached in by default, within the user's cache directory.
+func DefaultOrbCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config-compiler", "orbs"), nil
+}
+
+var pinnedVersionRegex = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// IsPinned reports whether the reference is to an exact semver version of an orb, whose source never changes.
+func (ref OrbRef) IsPinned() bool {
+	return pinnedVersionRegex.MatchString(ref.Version)
+}
+
+// CacheResolver caches the orbs resolved by another resolver on disk, under Dir laid out as
+// <namespace>/<name>@<version>.yml. Pinned orbs are cached forever, while other references such as
+// volatile or partial versions are resolved again once their cache entry is older than TTL.
+type CacheResolver struct {
+	Resolver OrbResolver
+	Dir      string
+	TTL      time.Duration
+	// Offline serves orbs from the cache only, regardless of their age, and fails with ErrOrbNotCached
+	// instead of calling Resolver on a cache miss.
+	Offline bool
+}
+
+func (cache CacheResolver) ResolveOrb(ref string) (string, error) {
+	orbRef, err := ParseOrbRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(cache.Dir, orbRef.Namespace, orbRef.Name+"@"+orbRef.Version+".yml")
+
+	info, err := os.Stat(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("failed to read orb cache: %w", err)
+	}
+
+	cached := err == nil
+	fresh := cached && (orbRef.IsPinned() || time.Since(info.ModTime()) < cache.TTL)
+
+	if fresh || (cached && cache.Offline) {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return "", fmt.Errorf("failed to read orb cache: %w", err)
+		}
+		return string(data), nil
+	}
+
+	if cache.Offline {
+		return "", fmt.Errorf("%w: %s", ErrOrbNotCached, ref)
+	}
+
+	src, err := cache.Resolver.ResolveOrb(ref)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeFileAtomic(filename, []byte(src)); err != nil {
+		return "", fmt.Errorf("failed to write orb cache: %w", err)
+	}
+
+	return src, nil
+}
+
+// writeFileAtomic writes the file through a temporary file, so that concurrent compilations never
+// read a partially written cache entry.
+func writeFileAtomic(filename string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,105 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheResolver(t *testing.T) {
+	var calls []string
+	upstream := config.OrbResolverFunc(func(ref string) (string, error) {
+		calls = append(calls, ref)
+		return config.MapResolver{
+			"acme/greet@1.0.0": greetOrb,
+			"acme/greet":       "commands: {}",
+		}.ResolveOrb(ref)
+	})
+
+	dir := t.TempDir()
+	cache := config.CacheResolver{Resolver: upstream, Dir: dir, TTL: time.Hour}
+
+	age := func(ref string) {
+		filename := filepath.Join(dir, filepath.FromSlash(ref)+".yml")
+		past := time.Now().Add(-2 * time.Hour)
+		require.NoError(t, os.Chtimes(filename, past, past))
+	}
+
+	t.Run("pinned refs never expire", func(t *testing.T) {
+		calls = nil
+
+		for i := 0; i < 2; i++ {
+			src, err := cache.ResolveOrb("acme/greet@1.0.0")
+			require.NoError(t, err)
+			require.Equal(t, greetOrb, src)
+		}
+		require.Equal(t, []string{"acme/greet@1.0.0"}, calls)
+
+		age("acme/greet@1.0.0")
+
+		_, err := cache.ResolveOrb("acme/greet@1.0.0")
+		require.NoError(t, err)
+		require.Len(t, calls, 1)
+	})
+
+	t.Run("volatile refs expire", func(t *testing.T) {
+		calls = nil
+
+		for i := 0; i < 2; i++ {
+			_, err := cache.ResolveOrb("acme/greet")
+			require.NoError(t, err)
+		}
+		require.Equal(t, []string{"acme/greet"}, calls)
+
+		age("acme/greet@volatile")
+
+		src, err := cache.ResolveOrb("acme/greet")
+		require.NoError(t, err)
+		require.Equal(t, "commands: {}", src)
+		require.Equal(t, []string{"acme/greet", "acme/greet"}, calls)
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		_, err := cache.ResolveOrb("acme/missing@1.0.0")
+		require.ErrorIs(t, err, config.ErrOrbNotFound)
+		require.NoFileExists(t, filepath.Join(dir, "acme", "missing@1.0.0.yml"))
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		calls = nil
+
+		offline := cache
+		offline.Offline = true
+
+		age("acme/greet@volatile")
+
+		src, err := offline.ResolveOrb("acme/greet@volatile")
+		require.NoError(t, err)
+		require.Equal(t, "commands: {}", src)
+
+		_, err = offline.ResolveOrb("acme/greet@2.0.0")
+		require.ErrorIs(t, err, config.ErrOrbNotCached)
+		require.EqualError(t, err, "orb not cached: acme/greet@2.0.0")
+
+		require.Empty(t, calls)
+	})
+
+	t.Run("refs cannot escape the cache directory", func(t *testing.T) {
+		root := t.TempDir()
+		escaping := config.CacheResolver{
+			Resolver: config.OrbResolverFunc(func(string) (string, error) { return "commands: {}", nil }),
+			Dir:      filepath.Join(root, "cache"),
+		}
+
+		for _, ref := range []string{"../evil@1.0.0", "acme/evil@../../1.0.0", `acme/evil@..\1.0.0`} {
+			_, err := escaping.ResolveOrb(ref)
+			require.ErrorContains(t, err, "invalid orb reference")
+		}
+		require.NoFileExists(t, filepath.Join(root, "evil@1.0.0.yml"))
+		require.NoDirExists(t, filepath.Join(root, "cache"))
+	})
+}
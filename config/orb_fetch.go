@@ -1,7 +1,15 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
 
 	"github.com/CircleCI-Public/circleci-cli/api"
 	"github.com/CircleCI-Public/circleci-cli/api/graphql"
@@ -9,6 +17,176 @@ import (
 
 var gql = graphql.NewClient(http.DefaultClient, "https://circleci.com", "graphql-unstable", "", false)
 
+// GetOrbSource fetches the source of an orb from the circleci.com registry.
 func GetOrbSource(ref string) (string, error) {
 	return api.OrbSource(gql, ref)
 }
+
+// ErrOrbNotFound is returned by resolvers that do not know of an orb. A ChainResolver moves on to its next
+// resolver when it is returned.
+var ErrOrbNotFound = errors.New("orb not found")
+
+// OrbResolver resolves an orb reference, such as circleci/node@5.1.0, to the source of the orb.
+type OrbResolver interface {
+	ResolveOrb(ref string) (string, error)
+}
+
+// OrbResolverFunc adapts a function to the OrbResolver interface.
+type OrbResolverFunc func(ref string) (string, error)
+
+func (fn OrbResolverFunc) ResolveOrb(ref string) (string, error) {
+	return fn(ref)
+}
+
+// OrbRef is a parsed orb reference of the form namespace/name@version. The version is "volatile"
+// when the reference does not specify one.
+type OrbRef struct {
+	Namespace string
+	Name      string
+	Version   string
+}
+
+func ParseOrbRef(ref string) (OrbRef, error) {
+	fullname, version, ok := strings.Cut(ref, "@")
+	if !ok {
+		version = "volatile"
+	}
+	namespace, name, ok := strings.Cut(fullname, "/")
+	if !ok || !validRefSegment(namespace) || !validRefSegment(name) || !validRefSegment(version) {
+		return OrbRef{}, fmt.Errorf("invalid orb reference %q: expected namespace/name@version", ref)
+	}
+	return OrbRef{Namespace: namespace, Name: name, Version: version}, nil
+}
+
+// validRefSegment reports whether a segment of an orb reference can safely be used as a path element, as
+// resolvers and the cache lay orbs out as <namespace>/<name>@<version>.yml.
+func validRefSegment(segment string) bool {
+	return segment != "" && segment != "." && segment != ".." && !strings.ContainsAny(segment, `/\`)
+}
+
+func (ref OrbRef) String() string {
+	return ref.Namespace + "/" + ref.Name + "@" + ref.Version
+}
+
+// MapResolver resolves orbs from memory, keyed by their full reference.
+type MapResolver map[string]string
+
+func (m MapResolver) ResolveOrb(ref string) (string, error) {
+	if src, ok := m[ref]; ok {
+		return src, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrOrbNotFound, ref)
+}
+
+// FSResolver resolves orbs from a file system laid out as <namespace>/<name>@<version>.yml.
+type FSResolver struct {
+	FS fs.FS
+}
+
+// DirResolver resolves orbs from a local directory laid out as <namespace>/<name>@<version>.yml.
+func DirResolver(dir string) FSResolver {
+	return FSResolver{FS: os.DirFS(dir)}
+}
+
+func (resolver FSResolver) ResolveOrb(ref string) (string, error) {
+	orbRef, err := ParseOrbRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ext := range []string{".yml", ".yaml"} {
+		data, err := fs.ReadFile(resolver.FS, path.Join(orbRef.Namespace, orbRef.Name+"@"+orbRef.Version+ext))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read orb %s: %w", ref, err)
+		}
+		return string(data), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrOrbNotFound, ref)
+}
+
+// HTTPResolver resolves orbs from an HTTP registry that serves the source of each orb
+// at <BaseURL>/<namespace>/<name>@<version>.
+type HTTPResolver struct {
+	BaseURL string
+	// Client is the client used for requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+func (resolver HTTPResolver) ResolveOrb(ref string) (string, error) {
+	orbRef, err := ParseOrbRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	client := resolver.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	endpoint, err := url.JoinPath(resolver.BaseURL, orbRef.Namespace, orbRef.Name+"@"+orbRef.Version)
+	if err != nil {
+		return "", fmt.Errorf("invalid registry url: %w", err)
+	}
+
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch orb %s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%w: %s", ErrOrbNotFound, ref)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("failed to fetch orb %s: %s", ref, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read orb %s: %w", ref, err)
+	}
+
+	return string(data), nil
+}
+
+// GraphQLResolver resolves orbs using the GraphQL API of CircleCI.
+type GraphQLResolver struct {
+	Client *graphql.Client
+}
+
+// NewGraphQLResolver returns a resolver for the GraphQL API of the CircleCI host, such as https://circleci.com.
+// The token may be empty for public orbs.
+func NewGraphQLResolver(host, token string) GraphQLResolver {
+	return GraphQLResolver{Client: graphql.NewClient(http.DefaultClient, host, "graphql-unstable", token, false)}
+}
+
+func (resolver GraphQLResolver) ResolveOrb(ref string) (string, error) {
+	client := resolver.Client
+	if client == nil {
+		client = gql
+	}
+
+	src, err := api.OrbSource(client, ref)
+	if err != nil && strings.HasPrefix(err.Error(), "no Orb") {
+		return "", fmt.Errorf("%w: %v", ErrOrbNotFound, err)
+	}
+	return src, err
+}
+
+// ChainResolver tries each of its resolvers in order, until one knows of the orb.
+type ChainResolver []OrbResolver
+
+func (chain ChainResolver) ResolveOrb(ref string) (string, error) {
+	for _, resolver := range chain {
+		src, err := resolver.ResolveOrb(ref)
+		if errors.Is(err, ErrOrbNotFound) {
+			continue
+		}
+		return src, err
+	}
+	return "", fmt.Errorf("%w: %s", ErrOrbNotFound, ref)
+}
@@ -0,0 +1,184 @@
+package config_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/stretchr/testify/require"
+)
+
+const greetOrb = `commands:
+  greet:
+    parameters:
+      to:
+        type: string
+        default: world
+    steps:
+      - run: echo hello << parameters.to >>
+`
+
+func TestParseOrbRef(t *testing.T) {
+	ref, err := config.ParseOrbRef("circleci/node@5.1.0")
+	require.NoError(t, err)
+	require.Equal(t, config.OrbRef{Namespace: "circleci", Name: "node", Version: "5.1.0"}, ref)
+
+	ref, err = config.ParseOrbRef("circleci/node")
+	require.NoError(t, err)
+	require.Equal(t, "volatile", ref.Version)
+
+	for _, invalid := range []string{
+		"node",
+		"/node@1.0.0",
+		"circleci/@1.0.0",
+		"circleci/node@",
+		"../node@1.0.0",
+		"./node@1.0.0",
+		"circleci/..@1.0.0",
+		"circleci/node/extra@1.0.0",
+		"circleci/node@1.0/../..",
+		"circleci/node@..",
+		`circleci\x/node@1.0.0`,
+		`circleci/node@1.0\..`,
+	} {
+		t.Run(invalid, func(t *testing.T) {
+			_, err := config.ParseOrbRef(invalid)
+			require.EqualError(t, err, fmt.Sprintf("invalid orb reference %q: expected namespace/name@version", invalid))
+		})
+	}
+}
+
+func TestOrbResolvers(t *testing.T) {
+	t.Run("map", func(t *testing.T) {
+		resolver := config.MapResolver{"acme/greet@1.0.0": greetOrb}
+
+		src, err := resolver.ResolveOrb("acme/greet@1.0.0")
+		require.NoError(t, err)
+		require.Equal(t, greetOrb, src)
+
+		_, err = resolver.ResolveOrb("acme/greet@2.0.0")
+		require.ErrorIs(t, err, config.ErrOrbNotFound)
+	})
+
+	t.Run("fs", func(t *testing.T) {
+		resolver := config.FSResolver{
+			FS: fstest.MapFS{
+				"acme/greet@1.0.0.yml":      {Data: []byte(greetOrb)},
+				"acme/greet@volatile.yaml":  {Data: []byte("commands: {}")},
+				"other/greet@1.0.0.yml.bak": {Data: []byte("commands: {}")},
+			},
+		}
+
+		src, err := resolver.ResolveOrb("acme/greet@1.0.0")
+		require.NoError(t, err)
+		require.Equal(t, greetOrb, src)
+
+		src, err = resolver.ResolveOrb("acme/greet")
+		require.NoError(t, err)
+		require.Equal(t, "commands: {}", src)
+
+		_, err = resolver.ResolveOrb("other/greet@1.0.0")
+		require.ErrorIs(t, err, config.ErrOrbNotFound)
+
+		_, err = resolver.ResolveOrb("greet")
+		require.EqualError(t, err, `invalid orb reference "greet": expected namespace/name@version`)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/orbs/acme/greet@1.0.0":
+				_, _ = w.Write([]byte(greetOrb))
+			case "/orbs/acme/broken@1.0.0":
+				w.WriteHeader(http.StatusInternalServerError)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		resolver := config.HTTPResolver{BaseURL: server.URL + "/orbs"}
+
+		src, err := resolver.ResolveOrb("acme/greet@1.0.0")
+		require.NoError(t, err)
+		require.Equal(t, greetOrb, src)
+
+		_, err = resolver.ResolveOrb("acme/missing@1.0.0")
+		require.ErrorIs(t, err, config.ErrOrbNotFound)
+
+		_, err = resolver.ResolveOrb("acme/broken@1.0.0")
+		require.EqualError(t, err, "failed to fetch orb acme/broken@1.0.0: 500 Internal Server Error")
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		var calls []string
+		tracked := func(name string, resolver config.OrbResolver) config.OrbResolver {
+			return config.OrbResolverFunc(func(ref string) (string, error) {
+				calls = append(calls, name)
+				return resolver.ResolveOrb(ref)
+			})
+		}
+
+		chain := config.ChainResolver{
+			tracked("first", config.MapResolver{}),
+			tracked("second", config.MapResolver{"acme/greet@1.0.0": greetOrb}),
+			tracked("third", config.MapResolver{"acme/greet@1.0.0": "unreachable"}),
+		}
+
+		src, err := chain.ResolveOrb("acme/greet@1.0.0")
+		require.NoError(t, err)
+		require.Equal(t, greetOrb, src)
+		require.Equal(t, []string{"first", "second"}, calls)
+
+		_, err = chain.ResolveOrb("acme/missing@1.0.0")
+		require.ErrorIs(t, err, config.ErrOrbNotFound)
+
+		failing := config.ChainResolver{
+			config.OrbResolverFunc(func(string) (string, error) { return "", errors.New("boom") }),
+			config.MapResolver{"acme/greet@1.0.0": greetOrb},
+		}
+
+		_, err = failing.ResolveOrb("acme/greet@1.0.0")
+		require.EqualError(t, err, "boom")
+	})
+}
+
+func TestCompileWithOrbResolver(t *testing.T) {
+	source := []byte(`version: 2.1
+
+orbs:
+  greet: acme/greet@1.0.0
+
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - greet/greet:
+          to: orbs
+
+workflows:
+  main:
+    jobs:
+      - test
+`)
+
+	compiler := config.Compiler{
+		OrbResolver: config.MapResolver{"acme/greet@1.0.0": greetOrb},
+	}
+
+	compiled, err := compiler.Compile(source, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, "echo hello orbs", runCommands(t, compiled, "test")[0])
+
+	compiler.OrbResolver = config.MapResolver{}
+
+	_, err = compiler.Compile(source, nil)
+	require.EqualError(t, err, "4:10: failed to get orb acme/greet@1.0.0: orb not found: acme/greet@1.0.0")
+	require.ErrorIs(t, err, config.ErrOrbNotFound)
+}
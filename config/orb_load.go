@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/davidmdm/yaml"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+type OrbVersionConflictErr struct {
+	Ref         string
+	ImportedBy  string
+	Conflict    string
+	ConflictsBy string
+}
+
+func (err OrbVersionConflictErr) Error() string {
+	return fmt.Sprintf(
+		"orb %s imported by %s conflicts with %s imported by %s",
+		err.Ref, err.ImportedBy, err.Conflict, err.ConflictsBy,
+	)
+}
+
+func (OrbVersionConflictErr) Code() ErrorCode { return CodeOrbVersionConflict }
+
+type OrbImportCycleErr []string
+
+func (err OrbImportCycleErr) Error() string {
+	return fmt.Sprintf("orb import cycle: %s", strings.Join(err, " -> "))
+}
+
+func (OrbImportCycleErr) Code() ErrorCode { return CodeOrbImportCycle }
+
+// orbLoader tracks the orbs loaded during a compilation, so that orbs imported more than once are fetched
+// once, and so that conflicting versions of the same orb are detected.
+type orbLoader struct {
+	// loaded maps the reference of every fetched orb to its key within the compiler's orbs.
+	loaded map[string]string
+	// versions maps the namespace/name of every fetched orb to the first import of the orb.
+	versions map[string]orbImport
+	locked   map[string]LockedOrb
+}
+
+type orbImport struct {
+	Ref string
+	By  string
+}
+
+// loadOrbs loads the orbs of the config along with every orb they import, and returns their lock entries.
+func (c *Compiler) loadOrbs() (map[string]LockedOrb, error) {
+	c.orbs = make(Orbs, len(c.root.Orbs))
+
+	loader := orbLoader{
+		loaded:   map[string]string{},
+		versions: map[string]orbImport{},
+		locked:   map[string]LockedOrb{},
+	}
+
+	names := maps.Keys(c.root.Orbs)
+	slices.Sort(names)
+
+	for _, name := range names {
+		if err := c.loadOrb(&loader, name, name, "", c.root.Orbs[name], nil); err != nil {
+			return nil, withContext("", "orbs."+name, err)
+		}
+	}
+
+	return loader.locked, nil
+}
+
+// loadOrb loads the orb declared by node under key. The file is the orb reference the declaration is
+// found in, or empty for the config itself, and the chain holds the references of the orbs importing it.
+func (c *Compiler) loadOrb(loader *orbLoader, key, name, file string, node RawNode, chain []string) error {
+	if node.Kind == yaml.MappingNode {
+		var inline Orb
+		if err := decode(node.Node, &inline); err != nil {
+			return setErrFile(withContext("invalid inline orb "+name, "", withCode(CodeInvalidOrb, err)), file)
+		}
+		inline.ref = file
+		return c.loadImports(loader, key, inline, chain)
+	}
+
+	var ref string
+	if err := decode(node.Node, &ref); err != nil {
+		return setErrFile(errAt(node.Node, withContext("invalid orb "+name, "", withCode(CodeInvalidOrb, err))), file)
+	}
+
+	if i := slices.Index(chain, ref); i >= 0 {
+		cycle := append(slices.Clone(chain[i:]), ref)
+		return setErrFile(errAt(node.Node, OrbImportCycleErr(cycle)), file)
+	}
+
+	importer := file
+	if importer == "" {
+		importer = "the config"
+	}
+
+	// The config may use several versions of an orb under different names, but the orbs it uses may not
+	// disagree with it or with each other.
+	fullname, _, _ := strings.Cut(ref, "@")
+	if previous, ok := loader.versions[fullname]; !ok {
+		loader.versions[fullname] = orbImport{Ref: ref, By: importer}
+	} else if previous.Ref != ref && (file != "" || previous.By != importer) {
+		err := OrbVersionConflictErr{Ref: ref, ImportedBy: importer, Conflict: previous.Ref, ConflictsBy: previous.By}
+		return setErrFile(errAt(node.Node, err), file)
+	}
+
+	if loadedKey, ok := loader.loaded[ref]; ok {
+		c.orbs[key] = c.orbs[loadedKey]
+		loader.locked[key] = loader.locked[loadedKey]
+		return nil
+	}
+
+	src, err := c.OrbResolver.ResolveOrb(ref)
+	if err != nil {
+		return setErrFile(errAt(node.Node, withCode(CodeOrbFetch, fmt.Errorf("failed to get orb %s: %w", ref, err))), file)
+	}
+
+	if loader.locked[key], err = c.OrbLock.lockOrb(key, ref, src); err != nil {
+		return setErrFile(errAt(node.Node, err), file)
+	}
+
+	var (
+		orbNode yaml.Node
+		orb     Orb
+	)
+	if err := yaml.Unmarshal([]byte(src), &orbNode); err != nil {
+		return withContext("failed to parse orb "+name, "", withCode(CodeInvalidOrb, setErrFile(yamlErr(err), ref)))
+	}
+	// An empty orb source has no node to decode, and defines nothing.
+	if orbNode.Kind != 0 {
+		if err := decode(&orbNode, &orb); err != nil {
+			return withContext("failed to parse orb "+name, "", withCode(CodeInvalidOrb, setErrFile(err, ref)))
+		}
+	}
+	orb.ref = ref
+
+	if err := c.loadImports(loader, key, orb, append(chain, ref)); err != nil {
+		return err
+	}
+
+	loader.loaded[ref] = key
+
+	return nil
+}
+
+// loadImports loads the orbs imported by the orb and validates its parameter declarations, before adding it
+// to the compiler's orbs under key.
+// Imported inline orbs are keyed by the key of the orb and their name, such as tools.helpers.
+func (c *Compiler) loadImports(loader *orbLoader, key string, orb Orb, chain []string) error {
+	orb.imports = make(map[string]string, len(orb.Orbs))
+
+	names := maps.Keys(orb.Orbs)
+	slices.Sort(names)
+
+	for _, name := range names {
+		node := orb.Orbs[name]
+
+		importKey := key + "." + name
+		if node.Kind == yaml.ScalarNode {
+			importKey = node.Value
+		}
+
+		if err := c.loadOrb(loader, importKey, name, orb.ref, node, chain); err != nil {
+			return withContext("orb "+key, "", err)
+		}
+
+		orb.imports[name] = importKey
+	}
+
+	if errs := declarationErrs(key, orb.Jobs, orb.Commands, orb.Executors); len(errs) > 0 {
+		return setErrFile(PrettyErr{Message: "parameter declaration error(s):", Errors: errs}, orb.ref)
+	}
+
+	c.orbs[key] = orb
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/davidmdm/yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitiveOrbs(t *testing.T) {
+	resolver := config.MapResolver{
+		"acme/deploy@1.0.0": `
+orbs:
+  greet: acme/greet@1.0.0
+executors:
+  default:
+    docker:
+      - image: deployer
+commands:
+  announce:
+    steps:
+      - greet/greet:
+          to: deploy
+jobs:
+  deploy:
+    executor: default
+    steps:
+      - announce
+      - run: ./deploy.sh
+`,
+		"acme/greet@1.0.0": greetOrb,
+		"acme/greet@2.0.0": greetOrb,
+		"acme/cycle-a@1.0.0": `
+orbs:
+  b: acme/cycle-b@1.0.0
+`,
+		"acme/cycle-b@1.0.0": `
+orbs:
+  a: acme/cycle-a@1.0.0
+`,
+	}
+
+	compile := func(t *testing.T, source string) ([]byte, error) {
+		t.Helper()
+		return config.Compiler{OrbResolver: resolver}.Compile([]byte(source), nil)
+	}
+
+	t.Run("imported orbs resolve in the scope of their orb", func(t *testing.T) {
+		compiled, err := compile(t, `version: 2.1
+
+orbs:
+  deploy: acme/deploy@1.0.0
+  hello: acme/greet@1.0.0
+
+workflows:
+  main:
+    jobs:
+      - deploy/deploy
+`)
+		require.NoError(t, err)
+
+		var result struct {
+			Jobs map[string]struct {
+				Docker []struct{ Image string } `yaml:"docker"`
+			} `yaml:"jobs"`
+		}
+		require.NoError(t, yaml.Unmarshal(compiled, &result))
+
+		require.Equal(t, "deployer", result.Jobs["deploy/deploy"].Docker[0].Image)
+		require.Equal(t, []string{"echo hello deploy", "./deploy.sh"}, runCommands(t, compiled, "deploy/deploy"))
+	})
+
+	t.Run("imported orbs are not visible to the config", func(t *testing.T) {
+		_, err := compile(t, `version: 2.1
+
+orbs:
+  deploy: acme/deploy@1.0.0
+
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - greet/greet
+
+workflows:
+  main:
+    jobs:
+      - test
+`)
+		require.EqualError(
+			t,
+			err,
+			"error processing workflow(s):\n"+
+				"  - workflow main: job test: could not compile step(s):\n"+
+				"    - 11:9: step 0: greet/greet: command not found",
+		)
+	})
+
+	t.Run("version conflict", func(t *testing.T) {
+		_, err := compile(t, `version: 2.1
+
+orbs:
+  deploy: acme/deploy@1.0.0
+  greet: acme/greet@2.0.0
+
+workflows:
+  main:
+    jobs:
+      - deploy/deploy
+`)
+		require.EqualError(
+			t,
+			err,
+			"5:10: orb acme/greet@2.0.0 imported by the config conflicts with acme/greet@1.0.0 imported by acme/deploy@1.0.0",
+		)
+		require.Equal(t, config.CodeOrbVersionConflict, config.Diagnose(err).Code)
+	})
+
+	t.Run("config may alias several versions", func(t *testing.T) {
+		_, err := compile(t, `version: 2.1
+
+orbs:
+  v1: acme/greet@1.0.0
+  v2: acme/greet@2.0.0
+
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - v1/greet
+      - v2/greet
+
+workflows:
+  main:
+    jobs:
+      - test
+`)
+		require.NoError(t, err)
+	})
+
+	t.Run("import cycle", func(t *testing.T) {
+		_, err := compile(t, `version: 2.1
+
+orbs:
+  a: acme/cycle-a@1.0.0
+
+workflows:
+  main:
+    jobs:
+      - a/job
+`)
+		require.EqualError(
+			t,
+			err,
+			"acme/cycle-b@1.0.0:3:6: orb a: orb acme/cycle-b@1.0.0: orb import cycle: acme/cycle-a@1.0.0 -> acme/cycle-b@1.0.0 -> acme/cycle-a@1.0.0",
+		)
+		require.Equal(t, config.CodeOrbImportCycle, config.Diagnose(err).Code)
+	})
+}
+
+func TestOrbLiteralBraces(t *testing.T) {
+	resolver := config.MapResolver{
+		"acme/tools@1.0.0": `
+commands:
+  list:
+    parameters:
+      format:
+        type: string
+        default: "{{.ID}}"
+    steps:
+      - run: docker ps --format '<< parameters.format >>' && docker images --format "{{.Repository}}:{{ .Tag }}"
+      - run: |
+          helm template . --set image={{ .Values.image }}
+          jq '{{name: .name}}' package.json
+`,
+	}
+
+	compiled, err := config.Compiler{OrbResolver: resolver}.Compile([]byte(`version: 2.1
+
+orbs:
+  tools: acme/tools@1.0.0
+
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - tools/list
+
+workflows:
+  main:
+    jobs:
+      - test
+`), nil)
+	require.NoError(t, err)
+
+	commands := runCommands(t, compiled, "test")
+	require.Len(t, commands, 2)
+	require.Equal(t, `docker ps --format '{{.ID}}' && docker images --format "{{.Repository}}:{{ .Tag }}"`, commands[0])
+	require.Equal(t, "helm template . --set image={{ .Values.image }}\njq '{{name: .name}}' package.json\n", commands[1])
+}
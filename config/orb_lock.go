@@ -0,0 +1,85 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/davidmdm/yaml"
+)
+
+// OrbLock records the orbs a config uses along with the digest of their source, as written to an orbs.lock
+// file, so that later compilations can detect orbs whose content changed under a pinned version.
+type OrbLock struct {
+	// Orbs is keyed by the name of the orb within the config.
+	Orbs map[string]LockedOrb `yaml:"orbs"`
+}
+
+type LockedOrb struct {
+	Ref string `yaml:"ref"`
+	// Digest is the SHA-256 of the orb source, of the form sha256:<hex>.
+	Digest string `yaml:"digest"`
+}
+
+type OrbDigestMismatchErr struct {
+	Ref  string
+	Want string
+	Got  string
+}
+
+func (err OrbDigestMismatchErr) Error() string {
+	return fmt.Sprintf("orb %s does not match the lockfile: wanted digest %s but got %s", err.Ref, err.Want, err.Got)
+}
+
+func (OrbDigestMismatchErr) Code() ErrorCode { return CodeOrbDigestMismatch }
+
+// ParseOrbLock parses the content of an orbs.lock file.
+func ParseOrbLock(data []byte) (*OrbLock, error) {
+	var lock OrbLock
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid orb lockfile: %w", err)
+	}
+	if lock.Orbs == nil {
+		lock.Orbs = map[string]LockedOrb{}
+	}
+	return &lock, nil
+}
+
+// Marshal returns the content of the orbs.lock file.
+func (lock OrbLock) Marshal() ([]byte, error) {
+	return yaml.Marshal(lock)
+}
+
+// lockOrb returns the lock entry of the orb source, failing when the lock holds a different digest for the
+// same reference. Orbs must be pinned to an exact version to be locked, as the source of other references
+// such as node@5 or volatile is expected to change.
+func (lock *OrbLock) lockOrb(name, ref, src string) (LockedOrb, error) {
+	orbRef, err := ParseOrbRef(ref)
+	if err != nil {
+		return LockedOrb{}, err
+	}
+
+	sum := sha256.Sum256([]byte(src))
+
+	entry := LockedOrb{
+		Ref:    ref,
+		Digest: "sha256:" + hex.EncodeToString(sum[:]),
+	}
+
+	if lock == nil {
+		return entry, nil
+	}
+
+	if !orbRef.IsPinned() {
+		return LockedOrb{}, withCode(CodeOrbNotPinned, fmt.Errorf(
+			"orb %s must be pinned to an exact version such as %s/%s@1.0.0 to be locked",
+			ref, orbRef.Namespace, orbRef.Name,
+		))
+	}
+
+	if locked, ok := lock.Orbs[name]; ok && locked.Ref == ref && locked.Digest != entry.Digest {
+		return LockedOrb{}, OrbDigestMismatchErr{Ref: ref, Want: locked.Digest, Got: entry.Digest}
+	}
+
+	return entry, nil
+}
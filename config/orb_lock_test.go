@@ -0,0 +1,93 @@
+package config_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrbLock(t *testing.T) {
+	source := []byte(`version: 2.1
+
+orbs:
+  greet: acme/greet@1.0.0
+
+jobs:
+  test:
+    docker:
+      - image: go
+    steps:
+      - greet/greet
+
+workflows:
+  main:
+    jobs:
+      - test
+`)
+
+	resolver := config.MapResolver{"acme/greet@1.0.0": greetOrb}
+
+	lock, err := config.ParseOrbLock(nil)
+	require.NoError(t, err)
+
+	compiler := config.Compiler{OrbResolver: resolver, OrbLock: lock}
+
+	_, err = compiler.Compile(source, nil)
+	require.NoError(t, err)
+
+	digest := "sha256:4450c80334c82fc07446ace1554ca8d457e097a12c5a3077731c1c3b9acf8b46"
+	require.Equal(
+		t,
+		map[string]config.LockedOrb{
+			"greet": {Ref: "acme/greet@1.0.0", Digest: digest},
+		},
+		lock.Orbs,
+	)
+
+	data, err := lock.Marshal()
+	require.NoError(t, err)
+
+	lock, err = config.ParseOrbLock(data)
+	require.NoError(t, err)
+
+	t.Run("unchanged", func(t *testing.T) {
+		compiler := config.Compiler{OrbResolver: resolver, OrbLock: lock}
+		_, err := compiler.Compile(source, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("unpinned orbs cannot be locked", func(t *testing.T) {
+		for _, ref := range []string{"acme/greet@1", "acme/greet@volatile"} {
+			compiler := config.Compiler{OrbResolver: config.MapResolver{ref: greetOrb}, OrbLock: lock}
+
+			_, err := compiler.Compile(bytes.Replace(source, []byte("acme/greet@1.0.0"), []byte(ref), 1), nil)
+			require.EqualError(t, err, "4:10: orb "+ref+" must be pinned to an exact version such as acme/greet@1.0.0 to be locked")
+			require.Equal(t, config.CodeOrbNotPinned, config.Diagnose(err).Code)
+		}
+	})
+
+	t.Run("pinned orbs may not change", func(t *testing.T) {
+		lock, err := config.ParseOrbLock(data)
+		require.NoError(t, err)
+
+		compiler := config.Compiler{
+			OrbResolver: config.MapResolver{"acme/greet@1.0.0": "commands: {greet: {steps: [checkout]}}"},
+			OrbLock:     lock,
+		}
+
+		_, err = compiler.Compile(source, nil)
+		require.EqualError(
+			t,
+			err,
+			"4:10: orb acme/greet@1.0.0 does not match the lockfile: wanted digest "+digest+
+				" but got sha256:147ac7176536f4a8ee1ab47c76566670d9df7f0bbcf20defb6b5ef5b78551b6f",
+		)
+		require.Equal(t, config.CodeOrbDigestMismatch, config.Diagnose(err).Code)
+
+		after, err := lock.Marshal()
+		require.NoError(t, err)
+		require.Equal(t, string(data), string(after), "lock must not be updated by a failed compilation")
+	})
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/davidmdm/yaml"
+)
+
+type PackConflictErr struct {
+	Key    string
+	First  string
+	Second string
+}
+
+func (err PackConflictErr) Error() string {
+	return fmt.Sprintf("%s is defined in both %s and %s", err.Key, err.First, err.Second)
+}
+
+func (PackConflictErr) Code() ErrorCode { return CodePackConflict }
+
+// Pack assembles a config split across a directory tree, as laid out for `circleci config pack`, into a single
+// source document. Each directory and each YAML file becomes a key of its parent, named after it without its
+// extension, while files prefixed with @, such as @config.yml, are merged into their parent directly. Maps
+// of definitions, such as the jobs of @config.yml and those of the jobs directory, are merged, and any other key
+// defined more than once is reported as a conflict.
+func Pack(fsys fs.FS) ([]byte, error) {
+	packer := packer{
+		fsys:      fsys,
+		origins:   map[string]string{},
+		mergeable: map[*yaml.Node]bool{},
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode}
+
+	if err := packer.packDir(root, ".", ""); err != nil {
+		return nil, err
+	}
+
+	if len(packer.errs) > 0 {
+		return nil, PrettyErr{Message: "failed to pack config:", Errors: packer.errs}
+	}
+
+	if len(root.Content) == 0 {
+		return nil, errors.New("failed to pack config: no YAML files found")
+	}
+
+	var result bytes.Buffer
+
+	encoder := yaml.NewEncoder(&result)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(root); err != nil {
+		return nil, err
+	}
+
+	return result.Bytes(), nil
+}
+
+type packer struct {
+	fsys fs.FS
+	// origins maps the path of every key, such as jobs.test, to the file it was defined in.
+	origins map[string]string
+	// mergeable holds the maps that other files may add keys to: the maps of directories, the content of
+	// files and the top-level values of @ files. Maps within them, such as the definition of a job, may not.
+	mergeable map[*yaml.Node]bool
+	errs      []error
+}
+
+func (p *packer) packDir(dst *yaml.Node, dir, keyPath string) error {
+	entries, err := fs.ReadDir(p.fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to pack config: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		filename := path.Join(dir, name)
+
+		if entry.IsDir() {
+			if err := p.packSubDir(dst, filename, joinPath(keyPath, name)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ext := path.Ext(name)
+		if ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+
+		data, err := fs.ReadFile(p.fsys, filename)
+		if err != nil {
+			return fmt.Errorf("failed to pack config: %w", err)
+		}
+
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			p.errs = append(p.errs, setErrFile(yamlErr(err), filename))
+			continue
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		node := doc.Content[0]
+		resolveAliases(node)
+
+		key := strings.TrimSuffix(name, ext)
+
+		if strings.HasPrefix(key, "@") {
+			if node.Kind != yaml.MappingNode {
+				err := withCode(CodeDecode, errors.New("expected a map of keys to merge into its directory"))
+				p.errs = append(p.errs, setErrFile(errAt(node, err), filename))
+				continue
+			}
+			for i := 1; i < len(node.Content); i += 2 {
+				p.mergeable[node.Content[i]] = true
+			}
+			p.merge(dst, node, keyPath, filename)
+			continue
+		}
+
+		p.mergeable[node] = true
+		p.merge(dst, mapping(key, node), keyPath, filename)
+	}
+
+	return nil
+}
+
+// packSubDir packs the directory into the mapping of dst named after it, which is added to dst when the
+// directory contains any YAML.
+func (p *packer) packSubDir(dst *yaml.Node, dir, keyPath string) error {
+	name := path.Base(dir)
+
+	child := mappingValue(dst, name)
+	if child != nil && (child.Kind != yaml.MappingNode || !p.mergeable[child]) {
+		p.errs = append(p.errs, PackConflictErr{Key: keyPath, First: p.origin(keyPath), Second: dir + "/"})
+		return nil
+	}
+
+	if child != nil {
+		return p.packDir(child, dir, keyPath)
+	}
+
+	child = &yaml.Node{Kind: yaml.MappingNode}
+	p.mergeable[child] = true
+
+	if err := p.packDir(child, dir, keyPath); err != nil {
+		return err
+	}
+	if len(child.Content) > 0 {
+		dst.Content = append(dst.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, child)
+		p.origins[keyPath] = dir + "/"
+	}
+
+	return nil
+}
+
+// merge merges the keys of the src mapping defined in file into dst, found at keyPath.
+func (p *packer) merge(dst, src *yaml.Node, keyPath, file string) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		valuePath := joinPath(keyPath, key.Value)
+
+		existing := mappingValue(dst, key.Value)
+		if existing == nil {
+			dst.Content = append(dst.Content, key, value)
+			p.origins[valuePath] = file
+			continue
+		}
+
+		if existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode && p.mergeable[existing] && p.mergeable[value] {
+			p.merge(existing, value, valuePath, file)
+			continue
+		}
+
+		p.errs = append(p.errs, PackConflictErr{Key: valuePath, First: p.origin(valuePath), Second: file})
+	}
+}
+
+// origin returns the file the key was defined in, or the file of its closest parent defined as a whole.
+func (p *packer) origin(keyPath string) string {
+	for {
+		if file, ok := p.origins[keyPath]; ok {
+			return file
+		}
+		idx := strings.LastIndex(keyPath, ".")
+		if idx < 0 {
+			return ""
+		}
+		keyPath = keyPath[:idx]
+	}
+}
+
+func mapping(key string, value *yaml.Node) *yaml.Node {
+	return &yaml.Node{
+		Kind:    yaml.MappingNode,
+		Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: key}, value},
+	}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package config_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/davidmdm/yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPack(t *testing.T) {
+	fsys := fstest.MapFS{
+		"@config.yml": {Data: []byte(`version: 2.1
+jobs:
+  lint:
+    executor: go
+    steps:
+      - run: make lint
+workflows:
+  main:
+    jobs:
+      - lint
+      - test
+`)},
+		"jobs/test.yml": {Data: []byte(`executor: go
+steps:
+  - greet
+  - run: make test
+`)},
+		"commands/greet.yml": {Data: []byte(`steps:
+  - run: echo hello
+`)},
+		"executors/@executors.yaml": {Data: []byte(`go:
+  docker:
+    - image: cimg/go:1.20
+`)},
+		"README.md": {Data: []byte("# not yaml")},
+	}
+
+	source, err := config.Pack(fsys)
+	require.NoError(t, err)
+
+	var packed map[string]any
+	require.NoError(t, yaml.Unmarshal(source, &packed))
+	require.ElementsMatch(t, []string{"version", "jobs", "workflows", "commands", "executors"}, keys(packed))
+	require.ElementsMatch(t, []string{"lint", "test"}, keys(packed["jobs"].(map[string]any)))
+
+	compiled, err := config.Compiler{}.Compile(source, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, "echo hello", runCommands(t, compiled, "test")[0])
+
+	t.Run("conflicts", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"@config.yml": {Data: []byte(`version: 2.1
+jobs:
+  test:
+    steps: [checkout]
+`)},
+			"jobs/test.yml":      {Data: []byte("steps: [checkout]")},
+			"version.yml":        {Data: []byte("2.1")},
+			"commands/greet.yml": {Data: []byte("steps: [checkout]")},
+			"commands.yml":       {Data: []byte("greet: {steps: [checkout]}")},
+		}
+
+		_, err := config.Pack(fsys)
+		require.EqualError(
+			t,
+			err,
+			"failed to pack config:\n"+
+				"  - commands.greet is defined in both commands/greet.yml and commands.yml\n"+
+				"  - jobs.test is defined in both @config.yml and jobs/test.yml\n"+
+				"  - version is defined in both @config.yml and version.yml",
+		)
+		require.Equal(t, config.CodePackConflict, config.Diagnose(err).Causes[0].Code)
+	})
+}
+
+func keys(m map[string]any) []string {
+	var result []string
+	for key := range m {
+		result = append(result, key)
+	}
+	return result
+}
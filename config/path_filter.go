@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/davidmdm/yaml"
+)
+
+// PathMapping is a line of the mapping given to the path-filtering orb: when a changed file matches Pattern,
+// the pipeline parameter named Parameter is set to Value.
+type PathMapping struct {
+	Pattern   *regexp.Regexp
+	Parameter string
+	Value     any
+}
+
+// pathMappingExpr splits a mapping line into its regex, parameter and value. The value is the remainder of the
+// line, so that JSON strings may contain spaces.
+var pathMappingExpr = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(.+)$`)
+
+// ParsePathMappings parses a mapping as given to the path-filtering orb, made of one mapping per line of the
+// form `<regex> <parameter> <value>`, where the value is JSON. Blank lines and lines starting with # are
+// ignored. Errors are located by their line within the mapping.
+func ParsePathMappings(mapping string) ([]PathMapping, error) {
+	var (
+		mappings []PathMapping
+		errs     []error
+	)
+
+	for i, line := range strings.Split(mapping, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		at := Position{Line: i + 1, Column: 1}
+
+		fields := pathMappingExpr.FindStringSubmatch(line)
+		if fields == nil {
+			err := fmt.Errorf("expected a mapping of the form <regex> <parameter> <value> but got: %s", line)
+			errs = append(errs, errAtPosition(at, withCode(CodeInvalidPathMapping, err)))
+			continue
+		}
+
+		expr, name, rawValue := fields[1], fields[2], fields[3]
+
+		pattern, err := regexp.Compile(`^(?:` + expr + `)$`)
+		if err != nil {
+			errs = append(errs, errAtPosition(at, withCode(CodeInvalidPathMapping, fmt.Errorf("invalid regex %s: %w", expr, err))))
+			continue
+		}
+
+		var value any
+		if err := yaml.Unmarshal([]byte(rawValue), &value); err != nil || value == nil {
+			errs = append(errs, errAtPosition(at, withCode(CodeInvalidPathMapping, fmt.Errorf("invalid value for parameter %s: %s", name, rawValue))))
+			continue
+		}
+
+		mappings = append(mappings, PathMapping{Pattern: pattern, Parameter: name, Value: value})
+	}
+
+	if len(errs) > 0 {
+		return nil, OrderedErr{Message: "invalid path mapping(s):", Errors: errs}
+	}
+
+	return mappings, nil
+}
+
+// FilterPaths returns the pipeline parameters set by the mappings for the changed files, as the path-filtering
+// orb would pass them to the continuation. A path matches a mapping when the mapping's regex matches it
+// entirely, and later mappings take precedence over earlier ones.
+func FilterPaths(mappings []PathMapping, changed []string) map[string]any {
+	parameters := map[string]any{}
+	for _, mapping := range mappings {
+		for _, path := range changed {
+			if mapping.Pattern.MatchString(path) {
+				parameters[mapping.Parameter] = mapping.Value
+				break
+			}
+		}
+	}
+	return parameters
+}
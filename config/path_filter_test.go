@@ -0,0 +1,86 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterPaths(t *testing.T) {
+	mappings, err := config.ParsePathMappings(`
+# service parameters
+services/api/.* build-api true
+services/web/.* build-web true
+docs/.* build-docs true
+.*\.go go-version "1.20"
+services/web/package.json node-version 18
+services/.* message "services changed"
+`)
+	require.NoError(t, err)
+
+	require.Equal(
+		t,
+		map[string]any{
+			"build-api":  true,
+			"go-version": "1.20",
+			"message":    "services changed",
+		},
+		config.FilterPaths(mappings, []string{"services/api/main.go", "README.md"}),
+	)
+
+	require.Equal(
+		t,
+		map[string]any{
+			"build-web":    true,
+			"node-version": 18,
+			"message":      "services changed",
+		},
+		config.FilterPaths(mappings, []string{"services/web/package.json"}),
+	)
+
+	// Patterns must match the entire path.
+	require.Empty(t, config.FilterPaths(mappings, []string{"old/docs/index.md"}))
+
+	t.Run("compile", func(t *testing.T) {
+		source := []byte(`version: 2.1
+parameters:
+  build-api:
+    type: boolean
+    default: false
+jobs:
+  api:
+    docker:
+      - image: go
+    steps:
+      - run: make api
+workflows:
+  api:
+    when: << pipeline.parameters.build-api >>
+    jobs:
+      - api
+`)
+
+		params := config.FilterPaths(mappings[:1], []string{"services/api/main.go"})
+		compiled, err := config.Compiler{}.Compile(source, map[string]any{"parameters": params})
+		require.NoError(t, err)
+		require.Contains(t, string(compiled), "make api")
+
+		compiled, err = config.Compiler{}.Compile(source, map[string]any{"parameters": config.FilterPaths(mappings[:1], nil)})
+		require.NoError(t, err)
+		require.NotContains(t, string(compiled), "make api")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := config.ParsePathMappings("src/.* build\n\n[a build true\nsrc/.* build [true")
+		require.EqualError(
+			t,
+			err,
+			"invalid path mapping(s):\n"+
+				"  - 1:1: expected a mapping of the form <regex> <parameter> <value> but got: src/.* build\n"+
+				"  - 3:1: invalid regex [a: error parsing regexp: missing closing ]: `[a)$`\n"+
+				"  - 4:1: invalid value for parameter build: [true",
+		)
+		require.Equal(t, config.CodeInvalidPathMapping, config.Diagnose(err).Causes[0].Code)
+	})
+}
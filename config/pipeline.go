@@ -0,0 +1,106 @@
+package config
+
+// PipelineValues are the values of the pipeline a config is compiled for, referenced within the config as
+// << pipeline.* >>. Every value defaults to its zero value, so that configs referencing values that are not
+// set, such as << pipeline.git.tag >> outside of tag pipelines, compile predictably.
+type PipelineValues struct {
+	// ID is the globally unique id of the pipeline, referenced as pipeline.id.
+	ID string
+	// Number is the project specific number of the pipeline, referenced as pipeline.number.
+	Number int
+
+	Project PipelineProject
+	Git     PipelineGit
+
+	// TriggerSource is the source that triggered the pipeline, such as webhook, api or scheduled_pipeline,
+	// referenced as pipeline.trigger_source.
+	TriggerSource string
+
+	Schedule PipelineSchedule
+
+	// Parameters are the values of the pipeline parameters declared by the config.
+	Parameters map[string]any
+}
+
+type PipelineProject struct {
+	// GitURL is the URL of the project's repository, referenced as pipeline.project.git_url.
+	GitURL string
+	// Type is the VCS provider of the project, such as github or bitbucket, referenced as pipeline.project.type.
+	Type string
+}
+
+type PipelineGit struct {
+	// Branch is referenced as pipeline.git.branch, and is empty for tag pipelines.
+	Branch string
+	// Tag is referenced as pipeline.git.tag, and is empty unless the pipeline was triggered by a tag.
+	Tag string
+	// Revision is the long git SHA being built, referenced as pipeline.git.revision.
+	Revision string
+	// BaseRevision is the long git SHA of the previous build of the branch, referenced as
+	// pipeline.git.base_revision.
+	BaseRevision string
+}
+
+type PipelineSchedule struct {
+	// Name is the name of the schedule that triggered the pipeline, referenced as pipeline.schedule.name.
+	Name string
+	// ID is the id of the schedule that triggered the pipeline, referenced as pipeline.schedule.id.
+	ID string
+}
+
+// Map returns the values in the form expected by Compiler.Compile.
+func (values PipelineValues) Map() map[string]any {
+	parameters := values.Parameters
+	if parameters == nil {
+		parameters = map[string]any{}
+	}
+
+	return map[string]any{
+		"id":     values.ID,
+		"number": values.Number,
+		"project": map[string]any{
+			"git_url": values.Project.GitURL,
+			"type":    values.Project.Type,
+		},
+		"git": map[string]any{
+			"branch":        values.Git.Branch,
+			"tag":           values.Git.Tag,
+			"revision":      values.Git.Revision,
+			"base_revision": values.Git.BaseRevision,
+		},
+		"trigger_source": values.TriggerSource,
+		"schedule": map[string]any{
+			"name": values.Schedule.Name,
+			"id":   values.Schedule.ID,
+		},
+		"parameters": parameters,
+	}
+}
+
+// CompilePipeline compiles the source config for the pipeline values.
+func (c Compiler) CompilePipeline(source []byte, values PipelineValues) ([]byte, error) {
+	return c.Compile(source, values.Map())
+}
+
+// withPipelineDefaults returns the pipeline values with the default of every value that is not set.
+func withPipelineDefaults(values map[string]any) map[string]any {
+	return mergeValues(PipelineValues{}.Map(), values)
+}
+
+// mergeValues returns the values of base overridden by those of values, merging nested maps.
+func mergeValues(base, values map[string]any) map[string]any {
+	result := make(map[string]any, len(base))
+	for key, value := range base {
+		result[key] = value
+	}
+	for key, value := range values {
+		baseMap, baseOk := result[key].(map[string]any)
+		valueMap, valueOk := value.(map[string]any)
+		if baseOk && valueOk {
+			result[key] = mergeValues(baseMap, valueMap)
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
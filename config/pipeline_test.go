@@ -0,0 +1,59 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/davidmdm/yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompilePipeline(t *testing.T) {
+	source := []byte(`version: 2.1
+
+parameters:
+  target:
+    type: string
+    default: staging
+
+jobs:
+  release:
+    docker:
+      - image: go
+    steps:
+      - run: release << pipeline.git.tag >> to << pipeline.parameters.target >> (<< pipeline.schedule.name >>)
+
+workflows:
+  release:
+    when:
+      matches:
+        pattern: ^v\d+
+        value: << pipeline.git.tag >>
+    jobs:
+      - release
+`)
+
+	compile := func(values config.PipelineValues) map[string]any {
+		t.Helper()
+
+		compiled, err := config.Compiler{}.CompilePipeline(source, values)
+		require.NoError(t, err)
+
+		var result map[string]any
+		require.NoError(t, yaml.Unmarshal(compiled, &result))
+		return result
+	}
+
+	result := compile(config.PipelineValues{
+		Git:        config.PipelineGit{Tag: "v1.2.0"},
+		Parameters: map[string]any{"target": "production"},
+	})
+	require.Equal(
+		t,
+		"release v1.2.0 to production ()",
+		result["jobs"].(map[string]any)["release"].(map[string]any)["steps"].([]any)[0].(map[string]any)["run"].(map[string]any)["command"],
+	)
+
+	result = compile(config.PipelineValues{Git: config.PipelineGit{Branch: "main"}})
+	require.Empty(t, result["workflows"])
+}
@@ -47,6 +47,34 @@ func topLevelKeys(typ reflect.Type) []string {
 	return result
 }
 
+// yamlFields returns the type of the field decoded for each of the keys returned by topLevelKeys.
+func yamlFields(typ reflect.Type) map[string]reflect.Type {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	result := map[string]reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tags := getYAMLTags(field)
+		if tags.Name == "-" {
+			continue
+		}
+		if tags.Inline {
+			for key, fieldType := range yamlFields(field.Type) {
+				result[key] = fieldType
+			}
+			continue
+		}
+		result[tags.Name] = field.Type
+	}
+
+	return result
+}
+
 func structToMap(item any, m map[string]any) map[string]any {
 	v := reflect.ValueOf(item)
 
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// requiresNode is a job of a workflow once its matrix is expanded, as a node of the graph of its requires.
+type requiresNode struct {
+	index    int
+	name     string
+	names    []string
+	requires []string
+	pos      Position
+}
+
+// validateRequires validates the graph formed by the requires of the jobs of a workflow, given the instances
+// of each of its jobs. It reports jobs requiring themselves or the same job more than once, cycles, and jobs
+// that depend on a cycle and can therefore never run. Requirements of jobs that do not exist are reported
+// separately.
+func validateRequires(workflow *Workflow, instances [][]matrixInstance) []error {
+	var nodes []requiresNode
+	for i, wfJob := range workflow.Jobs {
+		for _, instance := range instances[i] {
+			node := requiresNode{
+				index:    i,
+				name:     instance.Name,
+				names:    instance.Names,
+				requires: instance.Requires.Jobs(),
+				pos:      wfJob.pos,
+			}
+			if node.name == "" {
+				node.name = wfJob.Name()
+			}
+			if wfJob.Type == "approval" {
+				node.names = []string{wfJob.Name()}
+			}
+			nodes = append(nodes, node)
+		}
+	}
+
+	var errs []error
+
+	nodeErr := func(node requiresNode, code ErrorCode, err error) {
+		err = withCode(code, err)
+		errs = append(errs, withContext("", fmt.Sprintf("jobs[%d].requires", node.index), errAtPosition(node.pos, err)))
+	}
+
+	edges := make([][]int, len(nodes))
+	cyclic := make([]bool, len(nodes))
+	for i, node := range nodes {
+		for j, required := range node.requires {
+			if slices.Contains(node.requires[:j], required) {
+				nodeErr(node, CodeDuplicateRequirement, fmt.Errorf("job %s requires %s more than once", node.name, required))
+				continue
+			}
+			for k, other := range nodes {
+				if !slices.Contains(other.names, required) || slices.Contains(edges[i], k) {
+					continue
+				}
+				if k == i {
+					cyclic[i] = true
+					nodeErr(node, CodeRequiresCycle, fmt.Errorf("job %s requires itself", node.name))
+					continue
+				}
+				edges[i] = append(edges[i], k)
+			}
+		}
+	}
+
+	for _, component := range stronglyConnected(edges) {
+		if len(component) < 2 {
+			continue
+		}
+		for _, k := range component {
+			cyclic[k] = true
+		}
+
+		path := cyclePath(edges, component)
+
+		names := make([]string, len(path))
+		for i, k := range path {
+			names[i] = nodes[k].name
+		}
+		names = append(names, names[0])
+
+		nodeErr(nodes[path[0]], CodeRequiresCycle, fmt.Errorf("requires cycle: %s", strings.Join(names, " -> ")))
+	}
+
+	// Jobs that depend on a cycle, without being part of it, wait on jobs that never run.
+	blocked := make([]bool, len(nodes))
+	var isBlocked func(i int, seen []bool) bool
+	isBlocked = func(i int, seen []bool) bool {
+		if cyclic[i] || blocked[i] {
+			return true
+		}
+		if seen[i] {
+			return false
+		}
+		seen[i] = true
+		for _, k := range edges[i] {
+			if isBlocked(k, seen) {
+				blocked[i] = true
+				return true
+			}
+		}
+		return false
+	}
+	for i, node := range nodes {
+		if !cyclic[i] && isBlocked(i, make([]bool, len(nodes))) {
+			nodeErr(node, CodeRequiresCycle, fmt.Errorf("job %s can never run: it depends on a requires cycle", node.name))
+		}
+	}
+
+	return errs
+}
+
+// stronglyConnected returns the strongly connected components of the graph, using Tarjan's algorithm. Nodes
+// within each component are in ascending order.
+func stronglyConnected(edges [][]int) [][]int {
+	var (
+		index      int
+		indices    = make([]int, len(edges))
+		lowlinks   = make([]int, len(edges))
+		onStack    = make([]bool, len(edges))
+		stack      []int
+		components [][]int
+	)
+	for i := range indices {
+		indices[i] = -1
+	}
+
+	var connect func(v int)
+	connect = func(v int) {
+		indices[v], lowlinks[v] = index, index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range edges[v] {
+			switch {
+			case indices[w] < 0:
+				connect(w)
+				if lowlinks[w] < lowlinks[v] {
+					lowlinks[v] = lowlinks[w]
+				}
+			case onStack[w] && indices[w] < lowlinks[v]:
+				lowlinks[v] = indices[w]
+			}
+		}
+
+		if lowlinks[v] != indices[v] {
+			return
+		}
+
+		var component []int
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		slices.Sort(component)
+		components = append(components, component)
+	}
+
+	for v := range edges {
+		if indices[v] < 0 {
+			connect(v)
+		}
+	}
+
+	slices.SortFunc(components, func(a, b []int) bool { return a[0] < b[0] })
+
+	return components
+}
+
+// cyclePath returns the shortest cycle through the first node of the strongly connected component, starting
+// with that node.
+func cyclePath(edges [][]int, component []int) []int {
+	start := component[0]
+	previous := map[int]int{start: -1}
+	queue := []int{start}
+
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+
+		for _, w := range edges[v] {
+			if w == start {
+				var path []int
+				for ; v >= 0; v = previous[v] {
+					path = append([]int{v}, path...)
+				}
+				return path
+			}
+			if _, seen := previous[w]; seen || !slices.Contains(component, w) {
+				continue
+			}
+			previous[w] = v
+			queue = append(queue, w)
+		}
+	}
+
+	return component
+}
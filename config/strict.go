@@ -0,0 +1,262 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/davidmdm/yaml"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+type UnknownKeyErr struct {
+	Key        string
+	Suggestion string
+}
+
+func (err UnknownKeyErr) Error() string {
+	if err.Suggestion != "" {
+		return fmt.Sprintf("unknown key %s, did you mean %s?", err.Key, err.Suggestion)
+	}
+	return fmt.Sprintf("unknown key %s", err.Key)
+}
+
+func (UnknownKeyErr) Code() ErrorCode { return CodeUnknownKey }
+
+// strictExtraKeys are keys accepted in definitions that are not decoded into their type, as they do not carry
+// over to the compiled config.
+var strictExtraKeys = map[reflect.Type][]string{
+	reflect.TypeOf(Job{}):      {"description"},
+	reflect.TypeOf(Executor{}): {"description", "parameters", "shell", "working_directory", "environment"},
+	reflect.TypeOf(Workflow{}): {"unless"},
+}
+
+// strictTypes are the types that decode themselves but whose keys are those of their yaml struct tags.
+var strictTypes = []reflect.Type{
+	reflect.TypeOf(Workflow{}),
+	reflect.TypeOf(JobMatrix{}),
+	reflect.TypeOf(Trigger{}),
+	reflect.TypeOf(Schedule{}),
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// strictKeys reports the keys of the jobs, commands, executors and workflows of the config and of its inline
+// orbs that are not decoded, such as typos, which would otherwise silently be dropped from the compiled config.
+func (c Compiler) strictKeys() error {
+	var errs []error
+
+	errs = append(errs, unknownDefinitionKeys("jobs", c.root.Jobs, reflect.TypeOf(Job{}))...)
+	errs = append(errs, unknownDefinitionKeys("commands", c.root.Commands, reflect.TypeOf(Command{}))...)
+	errs = append(errs, unknownDefinitionKeys("executors", c.root.Executors, reflect.TypeOf(Executor{}))...)
+	errs = append(errs, unknownDefinitionKeys("workflows", c.root.Workflows, reflect.TypeOf(Workflow{}))...)
+	errs = append(errs, unknownOrbKeys("orbs", c.root.Orbs)...)
+
+	if len(errs) > 0 {
+		return PrettyErr{Message: "unknown key(s):", Errors: errs}
+	}
+
+	return nil
+}
+
+// unknownDefinitionKeys checks the definitions of the config or of an orb, such as its jobs, found at path.
+func unknownDefinitionKeys(path string, definitions map[string]RawNode, typ reflect.Type) []error {
+	var errs []error
+	names := maps.Keys(definitions)
+	slices.Sort(names)
+	for _, name := range names {
+		errs = append(errs, unknownKeys(definitions[name].Node, typ, joinPath(path, name))...)
+	}
+	return errs
+}
+
+// unknownOrbKeys checks the jobs, commands and executors of inline orbs, including those imported by other
+// inline orbs. Orbs that fail to decode are reported when loaded.
+func unknownOrbKeys(path string, orbs map[string]RawNode) []error {
+	var errs []error
+	names := maps.Keys(orbs)
+	slices.Sort(names)
+	for _, name := range names {
+		var orb Orb
+		if node := orbs[name].Node; node == nil || node.Kind != yaml.MappingNode || node.Decode(&orb) != nil {
+			continue
+		}
+		orbPath := joinPath(path, name)
+		errs = append(errs, unknownDefinitionKeys(orbPath+".jobs", orb.Jobs, reflect.TypeOf(Job{}))...)
+		errs = append(errs, unknownDefinitionKeys(orbPath+".commands", orb.Commands, reflect.TypeOf(Command{}))...)
+		errs = append(errs, unknownDefinitionKeys(orbPath+".executors", orb.Executors, reflect.TypeOf(Executor{}))...)
+		errs = append(errs, unknownOrbKeys(orbPath+".orbs", orb.Orbs)...)
+	}
+	return errs
+}
+
+// unknownKeys returns an error for every key of the node, found at path, that does not match a yaml tag of typ,
+// and of the values of the keys that do. Types that decode themselves from a different form, such as
+// environments or parameter values, are not checked.
+func unknownKeys(node *yaml.Node, typ reflect.Type, path string) []error {
+	if node == nil {
+		return nil
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch typ {
+	case reflect.TypeOf(Steps{}):
+		return unknownKeys(node, reflect.TypeOf([]Step{}), path)
+	case reflect.TypeOf(Step{}):
+		return unknownStepKeys(node, path)
+	case reflect.TypeOf(WorkflowJob{}):
+		return unknownWorkflowJobKeys(node, path)
+	}
+
+	if reflect.PointerTo(typ).Implements(unmarshalerType) && !slices.Contains(strictTypes, typ) {
+		return nil
+	}
+
+	var errs []error
+
+	switch typ.Kind() {
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, elem := range node.Content {
+			errs = append(errs, unknownKeys(elem, typ.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			errs = append(errs, unknownKeys(node.Content[i+1], typ.Elem(), joinPath(path, node.Content[i].Value))...)
+		}
+
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := yamlFields(typ)
+		keys := append(topLevelKeys(typ), strictExtraKeys[typ]...)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				errs = append(errs, unknownMergeKeys(value, typ, path)...)
+				continue
+			}
+			if field, ok := fields[key.Value]; ok {
+				errs = append(errs, unknownKeys(value, field, joinPath(path, key.Value))...)
+				continue
+			}
+			if !slices.Contains(keys, key.Value) {
+				errs = append(errs, unknownKeyErr(key, keys, path))
+			}
+		}
+	}
+
+	return errs
+}
+
+// unknownMergeKeys checks the maps merged into a map of type typ with the << merge key.
+func unknownMergeKeys(node *yaml.Node, typ reflect.Type, path string) []error {
+	if node.Kind != yaml.SequenceNode {
+		return unknownKeys(node, typ, path)
+	}
+	var errs []error
+	for _, merged := range node.Content {
+		errs = append(errs, unknownKeys(merged, typ, path)...)
+	}
+	return errs
+}
+
+// unknownStepKeys checks the arguments of built-in steps. The arguments of commands are validated against
+// their parameters instead.
+func unknownStepKeys(node *yaml.Node, path string) []error {
+	if node.Kind != yaml.MappingNode || len(node.Content) != 2 {
+		return nil
+	}
+
+	key, value := node.Content[0], node.Content[1]
+
+	field, ok := yamlFields(reflect.TypeOf(StepCMD{}))[key.Value]
+	if !ok {
+		return nil
+	}
+
+	return unknownKeys(value, field, joinPath(path, key.Value))
+}
+
+// unknownWorkflowJobKeys checks the values of the properties of a workflow job. Its other keys are the
+// arguments of the job, which are validated against its parameters instead.
+func unknownWorkflowJobKeys(node *yaml.Node, path string) []error {
+	if node.Kind != yaml.MappingNode || len(node.Content) != 2 || node.Content[1].Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var (
+		errs   []error
+		fields = yamlFields(reflect.TypeOf(WorkflowJobProps{}))
+		props  = node.Content[1]
+	)
+
+	for i := 0; i+1 < len(props.Content); i += 2 {
+		key, value := props.Content[i], props.Content[i+1]
+		if field, ok := fields[key.Value]; ok {
+			errs = append(errs, unknownKeys(value, field, joinPath(path, node.Content[0].Value+"."+key.Value))...)
+		}
+	}
+
+	return errs
+}
+
+func unknownKeyErr(key *yaml.Node, known []string, path string) error {
+	err := UnknownKeyErr{Key: key.Value, Suggestion: suggest(key.Value, known)}
+	return withContext("", joinPath(path, key.Value), errAt(key, err))
+}
+
+// suggest returns the candidate closest to value, if it is close enough to likely be what was meant.
+func suggest(value string, candidates []string) string {
+	var (
+		best     string
+		bestDist = len(value)/3 + 1
+	)
+	for _, candidate := range candidates {
+		if dist := levenshtein(value, candidate); dist <= bestDist && (best == "" || dist < bestDist) {
+			best, bestDist = candidate, dist
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	return result
+}
@@ -0,0 +1,167 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/davidmdm/config-compiler/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrict(t *testing.T) {
+	source := []byte(`version: 2.1
+
+executors:
+  go:
+    description: go executor
+    parameters:
+      version:
+        type: string
+        default: "1.20"
+    docker:
+      - image: cimg/go:<< parameters.version >>
+        enviroment:
+          CGO_ENABLED: 0
+
+commands:
+  greet:
+    descripton: greets
+    steps:
+      - run: echo hello
+
+jobs:
+  test:
+    description: runs the tests
+    executor: go
+    working_directroy: ~/project
+    steps:
+      - checkout
+      - greet
+      - run:
+          command: make test
+          no_output_timeout: 10m
+          backgroud: true
+      - save_cache:
+          key: go-mod
+          paths: [~/go/pkg/mod]
+          unknown: true
+
+workflows:
+  main:
+    triggers:
+      - schedule:
+          cron: 0 0 * * *
+          filters:
+            branches:
+              only: main
+          timezone: UTC
+    jobs:
+      - test:
+          context: org
+          filters:
+            branchs:
+              only: main
+`)
+
+	_, err := config.Compiler{}.Compile(source, nil)
+	require.NoError(t, err)
+
+	_, err = config.Compiler{Filename: "config.yml", Strict: true}.Compile(source, nil)
+	require.EqualError(
+		t,
+		err,
+		"unknown key(s):\n"+
+			"  - config.yml:12:9: unknown key enviroment, did you mean environment?\n"+
+			"  - config.yml:17:5: unknown key descripton, did you mean description?\n"+
+			"  - config.yml:25:5: unknown key working_directroy, did you mean working_directory?\n"+
+			"  - config.yml:32:11: unknown key backgroud, did you mean background?\n"+
+			"  - config.yml:36:11: unknown key unknown\n"+
+			"  - config.yml:46:11: unknown key timezone\n"+
+			"  - config.yml:51:13: unknown key branchs, did you mean branches?",
+	)
+
+	diagnostic := config.Diagnose(err)
+	require.Equal(t, config.CodeUnknownKey, diagnostic.Causes[0].Code)
+	require.Equal(t, "executors.go.docker[0].enviroment", diagnostic.Causes[0].Path)
+	require.Equal(t, "jobs.test.steps[2].run.backgroud", diagnostic.Causes[3].Path)
+	require.Equal(t, "workflows.main.jobs[0].test.filters.branchs", diagnostic.Causes[6].Path)
+
+	t.Run("inline orbs", func(t *testing.T) {
+		source := []byte(`version: 2.1
+
+orbs:
+  tools:
+    orbs:
+      helpers:
+        commands:
+          greet:
+            steps:
+              - run:
+                  command: echo hello
+                  backgroud: true
+    jobs:
+      lint:
+        docker:
+          - image: go
+        workign_directory: ~/project
+        steps:
+          - helpers/greet
+
+workflows:
+  main:
+    jobs:
+      - tools/lint
+`)
+
+		_, err := config.Compiler{Filename: "config.yml", Strict: true}.Compile(source, nil)
+		require.EqualError(
+			t,
+			err,
+			"unknown key(s):\n"+
+				"  - config.yml:12:19: unknown key backgroud, did you mean background?\n"+
+				"  - config.yml:17:9: unknown key workign_directory, did you mean working_directory?",
+		)
+
+		diagnostic := config.Diagnose(err)
+		require.Equal(t, "orbs.tools.orbs.helpers.commands.greet.steps[0].run.backgroud", diagnostic.Causes[0].Path)
+		require.Equal(t, "orbs.tools.jobs.lint.workign_directory", diagnostic.Causes[1].Path)
+	})
+
+	t.Run("schema keys", func(t *testing.T) {
+		source := []byte(`version: 2.1
+
+executors:
+  go:
+    docker:
+      - image: go
+    shell: /bin/bash
+    working_directory: ~/project
+    environment:
+      CGO_ENABLED: 0
+
+jobs:
+  test:
+    executor: go
+    circleci_ip_ranges: true
+    steps:
+      - checkout:
+          method: blobless
+      - add_ssh_keys:
+          name: add deploy key
+          fingerprints: [SO:ME:FIN:G:ER:PR:IN:T]
+      - run:
+          command: make test
+          max_auto_reruns: 3
+          auto_rerun_delay: 10s
+
+workflows:
+  main:
+    jobs:
+      - test
+`)
+
+		compiled, err := config.Compiler{Strict: true}.Compile(source, nil)
+		require.NoError(t, err)
+		require.Contains(t, string(compiled), "circleci_ip_ranges: true")
+		require.Contains(t, string(compiled), "max_auto_reruns: 3")
+	})
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/davidmdm/yaml"
 )
@@ -33,6 +36,98 @@ func decodeOneOrMore[T any, V ~[]T, P *V](node *yaml.Node, pointer P) error {
 	return nil
 }
 
+// Position is a line and column within a source document. Both are 1-based, and zero when unknown.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func positionOf(node *yaml.Node) Position {
+	return Position{Line: node.Line, Column: node.Column}
+}
+
+func setPositions(node *yaml.Node, pos Position) {
+	node.Line, node.Column = pos.Line, pos.Column
+	for _, child := range node.Content {
+		setPositions(child, pos)
+	}
+}
+
+// decode decodes node into out. The type errors of the yaml library only report a line within their message,
+// so they are positioned at the nodes that fail to decode instead.
+func decode(node *yaml.Node, out any) error {
+	err := node.Decode(out)
+	if !errors.As(err, new(*yaml.TypeError)) {
+		return yamlErr(err)
+	}
+
+	var errs []error
+	for _, failure := range decodeFailures(node, reflect.TypeOf(out).Elem()) {
+		var typeErr *yaml.TypeError
+		if !errors.As(failure.err, &typeErr) {
+			errs = append(errs, errAt(failure.node, failure.err))
+			continue
+		}
+		for _, msg := range typeErr.Errors {
+			if _, rest, ok := strings.Cut(msg, ": "); ok && strings.HasPrefix(msg, "line ") {
+				msg = rest
+			}
+			errs = append(errs, errAt(failure.node, withCode(CodeDecode, errors.New(msg))))
+		}
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return OrderedErr{Message: "yaml: unmarshal errors:", Errors: errs}
+}
+
+type decodeFailure struct {
+	node *yaml.Node
+	err  error
+}
+
+// decodeFailures returns the innermost nodes that fail to decode into typ, by decoding the children of node
+// into the types of the fields or elements they map to.
+func decodeFailures(node *yaml.Node, typ reflect.Type) []decodeFailure {
+	err := node.Decode(reflect.New(typ).Interface())
+	if err == nil {
+		return nil
+	}
+
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	var failures []decodeFailure
+
+	switch {
+	case node.Kind == yaml.DocumentNode && len(node.Content) == 1:
+		failures = decodeFailures(node.Content[0], typ)
+	case node.Kind == yaml.AliasNode && node.Alias != nil:
+		failures = decodeFailures(node.Alias, typ)
+	case node.Kind == yaml.SequenceNode && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array):
+		for _, child := range node.Content {
+			failures = append(failures, decodeFailures(child, typ.Elem())...)
+		}
+	case node.Kind == yaml.MappingNode && typ.Kind() == reflect.Map:
+		for i := 1; i < len(node.Content); i += 2 {
+			failures = append(failures, decodeFailures(node.Content[i], typ.Elem())...)
+		}
+	case node.Kind == yaml.MappingNode && typ.Kind() == reflect.Struct:
+		for i := 1; i < len(node.Content); i += 2 {
+			if field, ok := yamlFields(typ)[node.Content[i-1].Value]; ok {
+				failures = append(failures, decodeFailures(node.Content[i], field)...)
+			}
+		}
+	}
+
+	if len(failures) == 0 {
+		return []decodeFailure{{node: node, err: err}}
+	}
+	return failures
+}
+
 type RawNode struct{ *yaml.Node }
 
 func (n *RawNode) UnmarshalYAML(node *yaml.Node) error {
@@ -55,7 +150,7 @@ type List[T any] []T
 
 func (l *List[T]) UnmarshalYAML(node *yaml.Node) error {
 	if node.Kind != yaml.SequenceNode {
-		return fmt.Errorf("expected a string but got: %s", node.Tag)
+		return errAt(node, withCode(CodeDecode, fmt.Errorf("expected a string but got: %s", node.Tag)))
 	}
 	var (
 		results = make([]T, len(node.Content))
@@ -63,8 +158,8 @@ func (l *List[T]) UnmarshalYAML(node *yaml.Node) error {
 	)
 
 	for i, n := range node.Content {
-		if err := n.Decode(&results[i]); err != nil {
-			errs = append(errs, fmt.Errorf("position %d: %w", i, err))
+		if err := decode(n, &results[i]); err != nil {
+			errs = append(errs, withContext(fmt.Sprintf("position %d", i), fmt.Sprintf("[%d]", i), errAt(n, err)))
 		}
 	}
 